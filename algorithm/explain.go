@@ -0,0 +1,233 @@
+package algorithm
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"math"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Detail 记录算法对一个 node 的计算过程. 正常调度时为 nil, 所有方法都可以在 nil 上调用;
+// explain 时不为 nil, 此时不通过的原因写入 Message 而不是日志
+type Detail struct {
+	Metric string `json:"metric,omitempty"`
+	// Value 缓存中的指标值, 没有数据时为空
+	Value *float64 `json:"value,omitempty"`
+	// Reserved 调度预留增加的值
+	Reserved float64 `json:"reserved,omitempty"`
+	// Forecast 预测值, 只有 forecast 算法有
+	Forecast *float64 `json:"forecast,omitempty"`
+	// Threshold, RecoverThreshold 按 pod QoS class、node 和 pod annotation 覆盖后生效的阈值
+	Threshold        float64 `json:"threshold"`
+	RecoverThreshold float64 `json:"recoverThreshold,omitempty"`
+	Excluded         bool    `json:"excluded,omitempty"`
+	Stale            bool    `json:"stale,omitempty"`
+	// PodMemory, Capacity, Headroom memory fit 的 pod 内存、node 容量和阈值内剩余内存(byte)
+	PodMemory int64    `json:"podMemory,omitempty"`
+	Capacity  float64  `json:"capacity,omitempty"`
+	Headroom  *float64 `json:"headroom,omitempty"`
+	Message   string   `json:"message,omitempty"`
+}
+
+// observe 记录生效的阈值和缓存中的指标值
+func (d *Detail) observe(m conf.MetricConfig, n *controller.NodeMetric, reserved float64, now time.Time) {
+	if d == nil {
+		return
+	}
+	d.Metric, d.Threshold, d.RecoverThreshold = m.Name, m.Threshold, m.RecoverThreshold
+	if n == nil {
+		d.Message = "缓存中没有数据"
+		return
+	}
+	value := n.Value
+	d.Value, d.Reserved, d.Excluded, d.Stale = &value, reserved, n.Excluded, !n.Fresh(now)
+	if !n.Usable(now) {
+		d.Message = fmt.Sprintf("数据过期, 过期策略 %v", conf.Get().Staleness.Policy)
+	}
+}
+
+func (d *Detail) forecast(value float64) {
+	if d != nil {
+		d.Forecast = &value
+	}
+}
+
+func (d *Detail) fit(podMemory int64, capacity, headroom float64) {
+	if d != nil {
+		d.PodMemory, d.Capacity, d.Headroom = podMemory, capacity, &headroom
+	}
+}
+
+func (d *Detail) note(format string, args ...interface{}) {
+	if d != nil {
+		d.Message = fmt.Sprintf(format, args...)
+	}
+}
+
+// reject 记录 node 不通过预选的原因: 正常调度时打印日志, explain 时写入 Message
+func (d *Detail) reject(format string, args ...interface{}) {
+	if d == nil {
+		log.Infof(format, args...)
+		return
+	}
+	d.note(format, args...)
+}
+
+// Explanation pod 在每个 node 上每个预选、优选算法的结果
+type Explanation struct {
+	Pod      string         `json:"pod"`
+	QoSClass v1.PodQOSClass `json:"qosClass"`
+	// Skipped pod 通过 allowlist 或 annotation 跳过所有负载算法
+	Skipped bool              `json:"skipped"`
+	Nodes   []NodeExplanation `json:"nodes"`
+}
+
+// NodeExplanation 一个 node 的预选、优选结果
+type NodeExplanation struct {
+	Name string `json:"name"`
+	Fit  bool   `json:"fit"`
+	// FailedReasons 调度器看到的失败原因, 即第一个不通过的预选算法的原因
+	FailedReasons []string `json:"failedReasons,omitempty"`
+	// Score 最终得分, 只在通过预选的 node 之间归一化, 没有通过预选时为空
+	Score      *int64              `json:"score,omitempty"`
+	Predicates []PluginExplanation `json:"predicates"`
+	Priorities []PluginExplanation `json:"priorities"`
+}
+
+// PluginExplanation 一个算法在一个 node 上的结果
+type PluginExplanation struct {
+	Plugin string `json:"plugin"`
+	// Disabled 对该 pod 不生效
+	Disabled bool   `json:"disabled,omitempty"`
+	Error    string `json:"error,omitempty"`
+
+	// Fit, Reasons 预选结果
+	Fit     *bool    `json:"fit,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+
+	// Weight, RawScore, Score, Contribution 优选的权重、原始得分(0 ~ 1)、归一化得分和对最终得分的贡献
+	Weight       int64    `json:"weight,omitempty"`
+	RawScore     *float64 `json:"rawScore,omitempty"`
+	Score        *float64 `json:"score,omitempty"`
+	Contribution *float64 `json:"contribution,omitempty"`
+
+	Detail *Detail `json:"detail,omitempty"`
+}
+
+// Explain 在 nodeNames 上依次执行所有预选和优选算法,不修改缓存、预留和 metrics.
+// 与调度器不同, 一个预选失败后仍然执行后续预选, 优选对所有 node 执行, 但只在通过预选的 node 之间归一化
+func Explain(pod *v1.Pod, nodeNames []string) *Explanation {
+	conf.Lock.RLock()
+	defer conf.Lock.RUnlock()
+
+	policy := newPodPolicy(pod)
+	e := &Explanation{
+		Pod:      pod.Namespace + "/" + pod.Name,
+		QoSClass: podQOSClass(pod),
+		Skipped:  policy.skip,
+		Nodes:    make([]NodeExplanation, len(nodeNames)),
+	}
+
+	workqueue.ParallelizeUntil(nil, 16, len(nodeNames), func(i int) {
+		e.Nodes[i] = explainPredicates(pod, nodeNames[i], policy)
+	})
+	explainPriorities(pod, e.Nodes, policy)
+	return e
+}
+
+func explainPredicates(pod *v1.Pod, nodeName string, policy podPolicy) NodeExplanation {
+	ne := NodeExplanation{Name: nodeName, Fit: true}
+	for _, name := range plugins.predicatesSorted {
+		pe := PluginExplanation{Plugin: name}
+		if !policy.enabled(name) {
+			pe.Disabled = true
+			ne.Predicates = append(ne.Predicates, pe)
+			continue
+		}
+
+		pe.Detail = &Detail{}
+		fit, reasons, err := plugins.predicatesFuncs[name](pod, v1.Node{}, nodeName, pe.Detail)
+		if err != nil {
+			pe.Error = err.Error()
+			fit = false
+		}
+		pe.Fit, pe.Reasons = &fit, reasons
+		if !fit && ne.Fit {
+			ne.Fit, ne.FailedReasons = false, reasons
+		}
+		ne.Predicates = append(ne.Predicates, pe)
+	}
+	return ne
+}
+
+func explainPriorities(pod *v1.Pod, nodes []NodeExplanation, policy podPolicy) {
+	var priorities []string
+	var totalWeight int64
+	for _, name := range plugins.prioritySorted {
+		if policy.enabled(name) {
+			priorities = append(priorities, name)
+			totalWeight += plugins.priorityWeights[name]
+		}
+	}
+
+	// 原始得分只在通过预选的 node 之间归一化
+	var candidates []int
+	for i := range nodes {
+		if nodes[i].Fit {
+			candidates = append(candidates, i)
+		}
+	}
+	totals := make([]float64, len(nodes))
+	for _, name := range plugins.prioritySorted {
+		results := make([]PluginExplanation, len(nodes))
+		if !policy.enabled(name) {
+			for i := range results {
+				results[i] = PluginExplanation{Plugin: name, Disabled: true}
+			}
+		} else {
+			weight := plugins.priorityWeights[name]
+			workqueue.ParallelizeUntil(nil, 16, len(nodes), func(i int) {
+				pe := PluginExplanation{Plugin: name, Weight: weight, Detail: &Detail{}}
+				value, exist, err := plugins.priorityFuncs[name](pod, v1.Node{}, nodes[i].Name, pe.Detail)
+				if err != nil {
+					pe.Error = err.Error()
+				} else if exist {
+					pe.RawScore = &value
+				}
+				results[i] = pe
+			})
+
+			values := make([]float64, len(candidates))
+			exists := make([]bool, len(candidates))
+			for j, i := range candidates {
+				if results[i].RawScore != nil {
+					values[j], exists[j] = *results[i].RawScore, true
+				}
+			}
+			for j, score := range normalize(values, exists) {
+				i := candidates[j]
+				score := score
+				contribution := score * float64(weight) / float64(totalWeight)
+				results[i].Score, results[i].Contribution = &score, &contribution
+				totals[i] += contribution
+			}
+		}
+		for i := range nodes {
+			nodes[i].Priorities = append(nodes[i].Priorities, results[i])
+		}
+	}
+
+	for _, i := range candidates {
+		// 与 Prioritize 一致,没有生效的优选算法时所有 node 得分为 1
+		score := int64(1)
+		if len(priorities) != 0 {
+			score = int64(math.Round(totals[i]))
+		}
+		nodes[i].Score = &score
+	}
+}
@@ -0,0 +1,19 @@
+package algorithm
+
+import (
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"testing"
+)
+
+// withTestConfig 使用 c 作为当前配置,测试结束后恢复配置、生效的算法和 controller.NodeInfo
+func withTestConfig(t *testing.T, c *conf.Config) {
+	oldConf, oldPlugins, oldNodeInfo := conf.Get(), plugins, controller.NodeInfo
+	conf.Store(c)
+	t.Cleanup(func() {
+		if oldConf != nil {
+			conf.Store(oldConf)
+		}
+		plugins, controller.NodeInfo = oldPlugins, oldNodeInfo
+	})
+}
@@ -0,0 +1,201 @@
+package algorithm
+
+import (
+	"fmt"
+	"github.com/prometheus/common/log"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"kube-scheduler-extender/metrics"
+	"math"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func init() {
+	Register("metrics", RegisterMetricPlugins)
+}
+
+// RegisterMetricPlugins 为每个指标注册同名的预选和优选算法,默认按配置顺序执行
+func RegisterMetricPlugins(r *Registry, c *conf.Config) error {
+	for _, m := range c.Metrics {
+		if err := r.RegisterPredicate(m.Plugin, staticPredicate(newLoadPredicate(m))); err != nil {
+			return err
+		}
+		if err := r.RegisterPriority(m.Plugin, staticPriority(newLoadPriority(m)), m.Weight); err != nil {
+			return err
+		}
+
+		log.Infof("注册算法 %v, 指标: %v, 阈值: %v, 权重: %v", m.Plugin, m.Name, m.Threshold, m.Weight)
+		if len(m.QoSThresholds) != 0 {
+			log.Infof("算法 %v 按 pod QoS class 设置阈值: %v", m.Plugin, m.QoSThresholds)
+		}
+
+		if c.CapacityRequired(m) {
+			if err := r.RegisterPredicate(MemoryFitPlugin, memoryFitFactory(m, c.MemoryFit.Basis)); err != nil {
+				return err
+			}
+
+			log.Infof("注册算法 %v, 指标: %v, 阈值: %v, pod 内存默认按 %v 计算", MemoryFitPlugin, m.Name, m.Threshold, c.MemoryFit.Basis)
+		}
+
+		if f := m.Forecast; f != nil {
+			plugin := m.ForecastPlugin()
+			if err := r.RegisterPredicate(plugin, staticPredicate(newForecastPredicate(m))); err != nil {
+				return err
+			}
+			if err := r.RegisterPriority(plugin, staticPriority(newForecastPriority(m)), f.Weight); err != nil {
+				return err
+			}
+
+			log.Infof("注册算法 %v, 指标: %v, 预测方法: %v, 样本数: %v, 预测时长: %v, 权重: %v", plugin, m.Name, f.Method, f.Samples, f.Horizon, f.Weight)
+		}
+	}
+	return nil
+}
+
+// newLoadPredicate rejects a node if the metric exceeds its threshold
+func newLoadPredicate(m conf.MetricConfig) FitPredicate {
+	failMsg := fmt.Sprintf("node %v load high", m.Name)
+	staleMsg := fmt.Sprintf("node %v load data stale", m.Name)
+
+	return func(pod *v1.Pod, node v1.Node, nodeName string, detail *Detail) (bool, []string, error) {
+		m := metricFor(m, pod, &node, nodeName)
+		currentTime := time.Now()
+		controller.NodeInfo.Lock.RLock()
+		defer controller.NodeInfo.Lock.RUnlock()
+		n, exist := controller.NodeInfo.Get(m.Name, nodeName)
+		if !exist && detail == nil {
+			metrics.FilterCacheMiss.WithLabelValues(m.Name).Inc()
+		}
+		reserved := n.Reserved(m.Name, nodeName, currentTime)
+		detail.observe(m, n, reserved, currentTime)
+
+		// 数据可用时,节点指标加上预留超过调度阀值,检查失败
+		if exist && n.Usable(currentTime) {
+			value := n.Value + reserved
+			if m.Exceeds(value) {
+				detail.reject("pod %v/%v 不能调度 node %v,当前node %v 指标值 %v, 预留后 %v, 阈值 %v", pod.Name, pod.Namespace, nodeName, m.Name, n.Value, formatValue(value), m.Threshold)
+				return false, []string{failMsg}, nil
+			}
+			// 开启滞后时,超过高水位的节点回落到低水位以内之前一直被过滤
+			if m.Hysteresis() && n.Excluded {
+				detail.reject("pod %v/%v 不能调度 node %v,当前node %v 指标值 %v, 尚未回落到低水位 %v", pod.Name, pod.Namespace, nodeName, m.Name, n.Value, m.RecoverThreshold)
+				return false, []string{failMsg}, nil
+			}
+			return true, nil, nil
+		}
+
+		// 数据过期或缺失
+		if conf.Get().Staleness.Policy == conf.StalePolicyReject {
+			detail.reject("pod %v/%v 不能调度 node %v,node %v 数据过期或缺失", pod.Name, pod.Namespace, nodeName, m.Name)
+			return false, []string{staleMsg}, nil
+		}
+
+		return true, nil, nil
+	}
+}
+
+// newLoadPriority 按指标值打分,数据过期或缺失的节点没有得分
+func newLoadPriority(m conf.MetricConfig) FitPriority {
+	return func(pod *v1.Pod, node v1.Node, nodeName string, detail *Detail) (float64, bool, error) {
+		currentTime := time.Now()
+		controller.NodeInfo.Lock.RLock()
+		defer controller.NodeInfo.Lock.RUnlock()
+		n, exist := controller.NodeInfo.Get(m.Name, nodeName)
+		reserved := n.Reserved(m.Name, nodeName, currentTime)
+		detail.observe(m, n, reserved, currentTime)
+		if !exist || !n.Fresh(currentTime) {
+			log.Debugf("执行优选算法 %v,node %v 数据过期或缓存未命中", m.Plugin, nodeName)
+			return 0, false, nil
+		}
+
+		score := scoreValue(scoreMetricFor(m, pod, &node, nodeName), n.Value+reserved)
+		log.Debugf("执行优选算法 %v,node %v,原始得分 %v", m.Plugin, nodeName, formatValue(score))
+		return score, true, nil
+	}
+}
+
+// scoreValue 将指标值在 [ScoreMin, ScoreMax] 内线性映射为 [0, 1],越大越好
+func scoreValue(m conf.MetricConfig, value float64) float64 {
+	ratio := (value - m.ScoreMin) / (m.ScoreMax - m.ScoreMin)
+	if m.ScoreDirection == conf.ScoreLowerBetter {
+		ratio = 1 - ratio
+	}
+	return math.Max(0, math.Min(1, ratio))
+}
+
+// scoreMetricFor 按 pod annotation 或 QoS class 的阈值收窄打分范围,超过 pod 阈值的节点得分最低.
+// 开启 node 阈值时每个节点都收窄到自己生效的阈值,按到阈值的余量打分,阈值不同的节点之间才能比较
+func scoreMetricFor(m conf.MetricConfig, pod *v1.Pod, node *v1.Node, nodeName string) conf.MetricConfig {
+	if len(m.NodeThresholds) != 0 || conf.Get().NodeThresholdAnnotations {
+		return m.ScoreRange(metricFor(m, pod, node, nodeName).Threshold)
+	}
+	if pod == nil {
+		return m
+	}
+	if threshold, exist := podThreshold(m, pod); exist {
+		return m.ScoreRange(threshold)
+	}
+	if len(m.QoSThresholds) == 0 {
+		return m
+	}
+	return m.ScoreRangeFor(podQOSClass(pod))
+}
+
+// newForecastPredicate rejects a node if the metric is predicted to exceed its threshold within the horizon.
+// 数据过期、缺失或历史样本不足时不做判断,由 newLoadPredicate 按过期策略处理
+func newForecastPredicate(m conf.MetricConfig) FitPredicate {
+	failMsg := fmt.Sprintf("node %v load forecast high", m.Name)
+
+	return func(pod *v1.Pod, node v1.Node, nodeName string, detail *Detail) (bool, []string, error) {
+		m := metricFor(m, pod, &node, nodeName)
+		currentTime := time.Now()
+		controller.NodeInfo.Lock.RLock()
+		defer controller.NodeInfo.Lock.RUnlock()
+		n, exist := controller.NodeInfo.Get(m.Name, nodeName)
+		detail.observe(m, n, 0, currentTime)
+		if !exist || !n.Fresh(currentTime) {
+			return true, nil, nil
+		}
+
+		forecast, ok := n.Forecast(*m.Forecast)
+		if ok {
+			detail.forecast(forecast)
+		}
+		if ok && m.Exceeds(forecast) {
+			detail.reject("pod %v/%v 不能调度 node %v,当前node %v 指标值 %v, %v 后预测值 %v, 阈值 %v", pod.Name, pod.Namespace, nodeName, m.Name, n.Value, m.Forecast.Horizon, formatValue(forecast), m.Threshold)
+			return false, []string{failMsg}, nil
+		}
+		return true, nil, nil
+	}
+}
+
+// newForecastPriority 按预测值打分,历史样本不足时使用当前值
+func newForecastPriority(m conf.MetricConfig) FitPriority {
+	return func(pod *v1.Pod, node v1.Node, nodeName string, detail *Detail) (float64, bool, error) {
+		currentTime := time.Now()
+		controller.NodeInfo.Lock.RLock()
+		defer controller.NodeInfo.Lock.RUnlock()
+		n, exist := controller.NodeInfo.Get(m.Name, nodeName)
+		detail.observe(m, n, 0, currentTime)
+		if !exist || !n.Fresh(currentTime) {
+			return 0, false, nil
+		}
+
+		value, ok := n.Forecast(*m.Forecast)
+		if ok {
+			detail.forecast(value)
+		} else {
+			value = n.Value
+		}
+		score := scoreValue(scoreMetricFor(m, pod, &node, nodeName), value)
+		log.Debugf("执行优选算法 %v,node %v 预测值 %v,原始得分 %v", m.ForecastPlugin(), nodeName, formatValue(value), formatValue(score))
+		return score, true, nil
+	}
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
@@ -0,0 +1,183 @@
+package algorithm
+
+import (
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"kube-scheduler-extender/metrics"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLoadPredicateCacheMiss(t *testing.T) {
+	withTestConfig(t, &conf.Config{
+		Staleness: conf.StalenessConfig{Policy: conf.StalePolicyAllow},
+		Intervals: conf.IntervalsConfig{Overdue: time.Minute},
+	})
+	m := conf.MetricConfig{Name: "load", Plugin: "CheckLoadLoad", Comparison: conf.ComparisonAbove, Threshold: 80}
+	controller.NodeInfo = &controller.Nodes{NodeMetrics: map[string]map[string]*controller.NodeMetric{
+		m.Name: {"node-1": {NodeName: "node-1", Value: 50, CheckTime: time.Now()}},
+	}}
+
+	tests := []struct {
+		name     string
+		nodeName string
+		wantMiss float64
+	}{
+		{name: "缓存命中", nodeName: "node-1"},
+		{name: "缓存未命中", nodeName: "node-2", wantMiss: 1},
+	}
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	predicate := newLoadPredicate(m)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(metrics.FilterCacheMiss.WithLabelValues(m.Name))
+			ok, _, err := predicate(pod, v1.Node{}, tt.nodeName, nil)
+			if err != nil || !ok {
+				t.Fatalf("预选结果 %v, %v, 期望通过", ok, err)
+			}
+			if got := testutil.ToFloat64(metrics.FilterCacheMiss.WithLabelValues(m.Name)) - before; got != tt.wantMiss {
+				t.Errorf("filter_node_cache_miss_total 增加 %v, 期望 %v", got, tt.wantMiss)
+			}
+		})
+	}
+}
+
+func TestLoadPredicateStaleness(t *testing.T) {
+	m := conf.MetricConfig{Name: "load", Plugin: "CheckLoadLoad", Comparison: conf.ComparisonAbove, Threshold: 80}
+
+	tests := []struct {
+		name   string
+		policy string
+		// age 数据的时间, 为 0 时没有数据
+		age   time.Duration
+		value float64
+		want  bool
+	}{
+		{name: "数据未过期且低于阈值", policy: conf.StalePolicyReject, age: time.Second, value: 50, want: true},
+		{name: "数据未过期且超过阈值", policy: conf.StalePolicyAllow, age: time.Second, value: 90, want: false},
+		{name: "allow 缺失数据通过", policy: conf.StalePolicyAllow, want: true},
+		{name: "reject 缺失数据不通过", policy: conf.StalePolicyReject, want: false},
+		{name: "allow 过期数据通过", policy: conf.StalePolicyAllow, age: 90 * time.Second, value: 90, want: true},
+		{name: "reject 过期数据不通过", policy: conf.StalePolicyReject, age: 90 * time.Second, value: 50, want: false},
+		{name: "keep-last 宽限期内按最后的值判断", policy: conf.StalePolicyKeepLast, age: 90 * time.Second, value: 90, want: false},
+		{name: "keep-last 超过宽限期通过", policy: conf.StalePolicyKeepLast, age: 3 * time.Minute, value: 90, want: true},
+	}
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	predicate := newLoadPredicate(m)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTestConfig(t, &conf.Config{
+				Staleness: conf.StalenessConfig{Policy: tt.policy, GracePeriod: time.Minute},
+				Intervals: conf.IntervalsConfig{Overdue: time.Minute},
+			})
+			nodes := map[string]*controller.NodeMetric{}
+			if tt.age != 0 {
+				nodes["node-1"] = &controller.NodeMetric{NodeName: "node-1", Value: tt.value, CheckTime: time.Now().Add(-tt.age)}
+			}
+			controller.NodeInfo = &controller.Nodes{NodeMetrics: map[string]map[string]*controller.NodeMetric{m.Name: nodes}}
+
+			got, _, err := predicate(pod, v1.Node{}, "node-1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("预选结果 %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPriorityNodeThreshold(t *testing.T) {
+	withTestConfig(t, &conf.Config{
+		NodeThresholdAnnotations: true,
+		Intervals:                conf.IntervalsConfig{Overdue: time.Minute},
+	})
+	m := conf.MetricConfig{
+		Name: "load", Plugin: "CheckLoadLoad", Comparison: conf.ComparisonAbove, Threshold: 80,
+		ScoreDirection: conf.ScoreLowerBetter, ScoreMax: 100,
+		Forecast: &conf.ForecastConfig{Method: conf.ForecastLinear, Samples: 3, Horizon: time.Minute},
+	}
+	// 三个 node 的指标值相同,按到各自阈值的余量排序
+	thresholds := map[string]string{"node-strict": "60", "node-default": "", "node-loose": "95"}
+	nodes := map[string]*controller.NodeMetric{}
+	for nodeName := range thresholds {
+		nodes[nodeName] = &controller.NodeMetric{NodeName: nodeName, Value: 50, CheckTime: time.Now()}
+	}
+	controller.NodeInfo = &controller.Nodes{NodeMetrics: map[string]map[string]*controller.NodeMetric{m.Name: nodes}}
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	for name, priority := range map[string]FitPriority{"load": newLoadPriority(m), "forecast": newForecastPriority(m)} {
+		t.Run(name, func(t *testing.T) {
+			scores := map[string]float64{}
+			for nodeName, threshold := range thresholds {
+				node := v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}}
+				if threshold != "" {
+					node.Annotations = map[string]string{conf.ThresholdAnnotation(m.Name): threshold}
+				}
+				score, ok, err := priority(pod, node, nodeName, nil)
+				if err != nil || !ok {
+					t.Fatalf("node %v 优选结果 %v, %v", nodeName, ok, err)
+				}
+				scores[nodeName] = score
+			}
+			if !(scores["node-strict"] < scores["node-default"] && scores["node-default"] < scores["node-loose"]) {
+				t.Errorf("得分 %v, 期望 node-strict < node-default < node-loose", scores)
+			}
+		})
+	}
+}
+
+func TestLoadPriorityPodThreshold(t *testing.T) {
+	withTestConfig(t, &conf.Config{Intervals: conf.IntervalsConfig{Overdue: time.Minute}})
+	m := conf.MetricConfig{
+		Name: "load", Plugin: "CheckLoadLoad", Comparison: conf.ComparisonAbove, Threshold: 80,
+		ScoreDirection: conf.ScoreLowerBetter, ScoreMax: 100,
+	}
+	controller.NodeInfo = &controller.Nodes{NodeMetrics: map[string]map[string]*controller.NodeMetric{
+		m.Name: {
+			"node-1": {NodeName: "node-1", Value: 30, CheckTime: time.Now()},
+			"node-2": {NodeName: "node-2", Value: 70, CheckTime: time.Now()},
+		},
+	}}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        map[string]float64
+	}{
+		{name: "没有 annotation", want: map[string]float64{"node-1": 0.7, "node-2": 0.3}},
+		{
+			name:        "打分范围收窄到 pod 阈值,超过阈值的节点得分最低",
+			annotations: map[string]string{conf.ThresholdAnnotation("load"): "60"},
+			want:        map[string]float64{"node-1": 0.5, "node-2": 0},
+		},
+		{
+			name:        "annotation 格式错误时使用指标配置",
+			annotations: map[string]string{conf.ThresholdAnnotation("load"): "60%"},
+			want:        map[string]float64{"node-1": 0.7, "node-2": 0.3},
+		},
+	}
+
+	priority := newLoadPriority(m)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := policyPod("default", "", tt.annotations)
+			for nodeName, want := range tt.want {
+				score, ok, err := priority(pod, v1.Node{}, nodeName, nil)
+				if err != nil || !ok {
+					t.Fatalf("node %v 优选结果 %v, %v", nodeName, ok, err)
+				}
+				if math.Abs(score-want) > 1e-9 {
+					t.Errorf("node %v 得分 %v, 期望 %v", nodeName, score, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package algorithm
+
+import (
+	"fmt"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// MemoryFitPlugin 按节点剩余内存和 pod 的内存大小过滤节点
+const MemoryFitPlugin = "CheckMemoryFit"
+
+// memoryFitArgs CheckMemoryFit 的参数
+type memoryFitArgs struct {
+	// Basis pod 内存按 requests 还是 limits 计算,默认为 memory_fit_basis
+	Basis string `yaml:"basis"`
+}
+
+// memoryFitFactory 解码 CheckMemoryFit 的参数, basis 为默认的 pod 内存计算方式
+func memoryFitFactory(m conf.MetricConfig, basis string) PredicateFactory {
+	return func(raw conf.PluginArgs) (FitPredicate, error) {
+		args := memoryFitArgs{Basis: basis}
+		if err := raw.Decode(&args); err != nil {
+			return nil, err
+		}
+		if args.Basis != conf.MemoryFitRequests && args.Basis != conf.MemoryFitLimits {
+			return nil, fmt.Errorf("basis 只支持 %v/%v", conf.MemoryFitRequests, conf.MemoryFitLimits)
+		}
+		return newMemoryFitPredicate(m, args.Basis), nil
+	}
+}
+
+// newMemoryFitPredicate rejects a node if the pod's memory does not fit under the threshold headroom.
+// 剩余空间 = 容量 × (阈值 - 当前使用率 - 预留) / 100, 数据过期、缺失或没有容量时由 newLoadPredicate 处理
+func newMemoryFitPredicate(m conf.MetricConfig, basis string) FitPredicate {
+	failMsg := fmt.Sprintf("node %v insufficient for pod", m.Name)
+
+	return func(pod *v1.Pod, node v1.Node, nodeName string, detail *Detail) (bool, []string, error) {
+		size := podMemory(pod, basis)
+		if size == 0 {
+			detail.note("pod 没有设置内存 %v", basis)
+			return true, nil, nil
+		}
+		m := metricFor(m, pod, &node, nodeName)
+
+		currentTime := time.Now()
+		controller.NodeInfo.Lock.RLock()
+		defer controller.NodeInfo.Lock.RUnlock()
+		n, exist := controller.NodeInfo.Get(m.Name, nodeName)
+		reserved := n.Reserved(m.Name, nodeName, currentTime)
+		detail.observe(m, n, reserved, currentTime)
+		if !exist || !n.Usable(currentTime) || n.Capacity <= 0 {
+			return true, nil, nil
+		}
+
+		used := n.Value + reserved
+		headroom := n.Capacity * (m.Threshold - used) / 100
+		detail.fit(size, n.Capacity, headroom)
+		if float64(size) > headroom {
+			detail.reject("pod %v/%v 不能调度 node %v,pod 内存 %v, node 阈值内剩余 %v", pod.Name, pod.Namespace, nodeName,
+				resource.NewQuantity(size, resource.BinarySI), resource.NewQuantity(int64(headroom), resource.BinarySI))
+			return false, []string{failMsg}, nil
+		}
+		return true, nil, nil
+	}
+}
+
+// podMemory 返回 pod 的内存大小(byte), basis 为 limits 时没有设置 limit 的容器使用 request
+func podMemory(pod *v1.Pod, basis string) int64 {
+	if basis != conf.MemoryFitLimits {
+		memory, _ := controller.PodRequests(pod)
+		return memory
+	}
+
+	containerMemory := func(c v1.Container) resource.Quantity {
+		if limit, exist := c.Resources.Limits[v1.ResourceMemory]; exist {
+			return limit
+		}
+		return *c.Resources.Requests.Memory()
+	}
+
+	memory := resource.Quantity{}
+	for _, c := range pod.Spec.Containers {
+		memory.Add(containerMemory(c))
+	}
+	for _, c := range pod.Spec.InitContainers {
+		if q := containerMemory(c); q.Cmp(memory) > 0 {
+			memory = q
+		}
+	}
+	return memory.Value()
+}
@@ -0,0 +1,124 @@
+package algorithm
+
+import (
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// memoryContainer 返回内存 request 和 limit 为指定值的容器,为空时不设置
+func memoryContainer(request, limit string) v1.Container {
+	c := v1.Container{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{}, Limits: v1.ResourceList{}}}
+	if request != "" {
+		c.Resources.Requests[v1.ResourceMemory] = resource.MustParse(request)
+	}
+	if limit != "" {
+		c.Resources.Limits[v1.ResourceMemory] = resource.MustParse(limit)
+	}
+	return c
+}
+
+func memoryPod(containers []v1.Container, initContainers ...v1.Container) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       v1.PodSpec{Containers: containers, InitContainers: initContainers},
+	}
+}
+
+func TestPodMemory(t *testing.T) {
+	tests := []struct {
+		name  string
+		pod   *v1.Pod
+		basis string
+		want  int64
+	}{
+		{name: "requests 相加", pod: memoryPod([]v1.Container{memoryContainer("100", "400"), memoryContainer("200", "")}), basis: conf.MemoryFitRequests, want: 300},
+		{name: "limits 没有 limit 的容器使用 request", pod: memoryPod([]v1.Container{memoryContainer("100", "400"), memoryContainer("200", "")}), basis: conf.MemoryFitLimits, want: 600},
+		{name: "没有设置内存", pod: memoryPod([]v1.Container{memoryContainer("", "")}), basis: conf.MemoryFitLimits, want: 0},
+		{
+			name:  "requests init container 更大时取 init container",
+			pod:   memoryPod([]v1.Container{memoryContainer("100", "")}, memoryContainer("500", "")),
+			basis: conf.MemoryFitRequests,
+			want:  500,
+		},
+		{
+			name:  "limits init container 更大时取 init container",
+			pod:   memoryPod([]v1.Container{memoryContainer("100", "200")}, memoryContainer("100", "800")),
+			basis: conf.MemoryFitLimits,
+			want:  800,
+		},
+		{
+			name:  "init container 更小时取容器之和",
+			pod:   memoryPod([]v1.Container{memoryContainer("100", "200"), memoryContainer("100", "200")}, memoryContainer("300", "300")),
+			basis: conf.MemoryFitLimits,
+			want:  400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := podMemory(tt.pod, tt.basis); got != tt.want {
+				t.Errorf("podMemory() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryFitPredicate(t *testing.T) {
+	m := conf.MetricConfig{Name: conf.MemoryMetricName, Plugin: "CheckMemoryLoad", Comparison: conf.ComparisonAbove, Threshold: 80}
+	// 容量 1000 byte, 使用率 50%, 阈值内剩余 300 byte
+	fresh := &controller.NodeMetric{NodeName: "node-1", Value: 50, Capacity: 1000}
+
+	tests := []struct {
+		name   string
+		basis  string
+		pod    *v1.Pod
+		metric *controller.NodeMetric
+		want   bool
+	}{
+		{name: "剩余空间足够", basis: conf.MemoryFitRequests, pod: memoryPod([]v1.Container{memoryContainer("200", "")}), metric: fresh, want: true},
+		{name: "正好等于剩余空间", basis: conf.MemoryFitRequests, pod: memoryPod([]v1.Container{memoryContainer("300", "")}), metric: fresh, want: true},
+		{name: "超过剩余空间", basis: conf.MemoryFitRequests, pod: memoryPod([]v1.Container{memoryContainer("301", "")}), metric: fresh, want: false},
+		{name: "按 requests 可以放下", basis: conf.MemoryFitRequests, pod: memoryPod([]v1.Container{memoryContainer("200", "400")}), metric: fresh, want: true},
+		{name: "按 limits 放不下", basis: conf.MemoryFitLimits, pod: memoryPod([]v1.Container{memoryContainer("200", "400")}), metric: fresh, want: false},
+		{name: "init container 放不下", basis: conf.MemoryFitRequests, pod: memoryPod([]v1.Container{memoryContainer("100", "")}, memoryContainer("400", "")), metric: fresh, want: false},
+		{name: "pod 没有设置内存", basis: conf.MemoryFitRequests, pod: memoryPod([]v1.Container{memoryContainer("", "")}), metric: fresh, want: true},
+		{
+			name:   "没有容量时由负载预选处理",
+			basis:  conf.MemoryFitRequests,
+			pod:    memoryPod([]v1.Container{memoryContainer("1Gi", "")}),
+			metric: &controller.NodeMetric{NodeName: "node-1", Value: 50},
+			want:   true,
+		},
+		{name: "没有数据", basis: conf.MemoryFitRequests, pod: memoryPod([]v1.Container{memoryContainer("1Gi", "")}), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTestConfig(t, &conf.Config{
+				MemoryFit: conf.MemoryFitConfig{Enabled: true, Basis: tt.basis},
+				Intervals: conf.IntervalsConfig{Overdue: time.Minute},
+			})
+			nodes := map[string]*controller.NodeMetric{}
+			if tt.metric != nil {
+				metric := *tt.metric
+				metric.CheckTime = time.Now()
+				nodes["node-1"] = &metric
+			}
+			controller.NodeInfo = &controller.Nodes{NodeMetrics: map[string]map[string]*controller.NodeMetric{m.Name: nodes}}
+
+			got, _, err := newMemoryFitPredicate(m, conf.Get().MemoryFit.Basis)(tt.pod, v1.Node{}, "node-1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("预选结果 %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package algorithm
+
+import (
+	"github.com/prometheus/common/log"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// podPolicy pod 通过 annotation 或全局 allowlist 对负载算法的选择
+type podPolicy struct {
+	// skip 为 true 时跳过所有负载算法
+	skip bool
+	// disabled 对该 pod 不生效的算法
+	disabled map[string]bool
+}
+
+func newPodPolicy(pod *v1.Pod) podPolicy {
+	var policy podPolicy
+	if pod == nil {
+		return policy
+	}
+
+	c := conf.Get().PodPolicy
+	switch {
+	case c.BypassNamespaces[pod.Namespace]:
+		log.Debugf("pod %v/%v 所在 namespace 在 allowlist 中,跳过负载算法", pod.Name, pod.Namespace)
+		policy.skip = true
+	case pod.Spec.PriorityClassName != "" && c.BypassPriorityClasses[pod.Spec.PriorityClassName]:
+		log.Debugf("pod %v/%v PriorityClass %v 在 allowlist 中,跳过负载算法", pod.Name, pod.Namespace, pod.Spec.PriorityClassName)
+		policy.skip = true
+	case pod.Annotations[conf.SkipAnnotation] == "true":
+		log.Debugf("pod %v/%v annotation %v 为 true,跳过负载算法", pod.Name, pod.Namespace, conf.SkipAnnotation)
+		policy.skip = true
+	}
+
+	if v := pod.Annotations[conf.DisablePluginsAnnotation]; v != "" {
+		policy.disabled = make(map[string]bool)
+		for _, plugin := range strings.Split(v, ",") {
+			policy.disabled[strings.TrimSpace(plugin)] = true
+		}
+	}
+	return policy
+}
+
+// enabled 判断算法对该 pod 是否生效
+func (p podPolicy) enabled(plugin string) bool {
+	return !p.skip && !p.disabled[plugin]
+}
+
+// metricFor 返回依次按 node 规则、node annotation、pod QoS class、pod annotation 覆盖阈值后的指标配置.
+// QoS 阈值替代指标的阈值, node 覆盖了阈值时取两者中更严格的, 低水位沿用 node 的配置(越过生效的阈值时关闭滞后);
+// pod annotation kube-scheduler-extender/<metric>-threshold 只对该 pod 生效,此时不使用 node 的高低水位状态
+func metricFor(m conf.MetricConfig, pod *v1.Pod, node *v1.Node, nodeName string) conf.MetricConfig {
+	base := m.Threshold
+	m = controller.MetricForNode(m, node, nodeName)
+	if pod == nil {
+		return m
+	}
+
+	if qos, exist := m.ForQoS(podQOSClass(pod)); exist {
+		// 阈值与指标配置相同时 node 没有覆盖阈值
+		if m.Threshold == base || qos.Stricter(m.Threshold) {
+			m = qos
+		}
+	}
+
+	threshold, exist := podThreshold(m, pod)
+	if !exist {
+		return m
+	}
+	m.Threshold = threshold
+	m.RecoverThreshold = 0
+	return m
+}
+
+// podThreshold 返回 pod annotation kube-scheduler-extender/<metric>-threshold 覆盖的阈值
+func podThreshold(m conf.MetricConfig, pod *v1.Pod) (float64, bool) {
+	v, exist := pod.Annotations[conf.ThresholdAnnotation(m.Name)]
+	if !exist {
+		return 0, false
+	}
+	threshold, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Warnf("pod %v/%v 的 %v 阈值 annotation 格式错误: %v", pod.Name, pod.Namespace, m.Name, err)
+		return 0, false
+	}
+	return threshold, true
+}
@@ -0,0 +1,183 @@
+package algorithm
+
+import (
+	"kube-scheduler-extender/conf"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func policyPod(namespace, priorityClass string, annotations map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "web", Annotations: annotations},
+		Spec:       v1.PodSpec{PriorityClassName: priorityClass},
+	}
+}
+
+func TestNewPodPolicy(t *testing.T) {
+	withTestConfig(t, &conf.Config{PodPolicy: conf.PodPolicyConfig{
+		BypassNamespaces:      map[string]bool{"kube-system": true, "monitoring": true},
+		BypassPriorityClasses: map[string]bool{"system-node-critical": true},
+	}})
+
+	tests := []struct {
+		name        string
+		pod         *v1.Pod
+		wantEnabled map[string]bool
+	}{
+		{name: "没有 pod", wantEnabled: map[string]bool{"CheckMemoryLoad": true}},
+		{
+			name:        "没有 annotation",
+			pod:         policyPod("default", "", nil),
+			wantEnabled: map[string]bool{"CheckMemoryLoad": true, "CheckCpuLoad": true},
+		},
+		{
+			name:        "namespace 在 allowlist 中",
+			pod:         policyPod("monitoring", "", nil),
+			wantEnabled: map[string]bool{"CheckMemoryLoad": false, "CheckCpuLoad": false},
+		},
+		{
+			name:        "PriorityClass 在 allowlist 中",
+			pod:         policyPod("default", "system-node-critical", nil),
+			wantEnabled: map[string]bool{"CheckMemoryLoad": false},
+		},
+		{
+			name:        "PriorityClass 不在 allowlist 中",
+			pod:         policyPod("default", "high", nil),
+			wantEnabled: map[string]bool{"CheckMemoryLoad": true},
+		},
+		{
+			name:        "skip annotation",
+			pod:         policyPod("default", "", map[string]string{conf.SkipAnnotation: "true"}),
+			wantEnabled: map[string]bool{"CheckMemoryLoad": false, "CheckCpuLoad": false},
+		},
+		{
+			name:        "skip annotation 不是 true",
+			pod:         policyPod("default", "", map[string]string{conf.SkipAnnotation: "yes"}),
+			wantEnabled: map[string]bool{"CheckMemoryLoad": true},
+		},
+		{
+			name:        "disable-plugins annotation",
+			pod:         policyPod("default", "", map[string]string{conf.DisablePluginsAnnotation: "CheckCpuLoad, CheckLoadLoad"}),
+			wantEnabled: map[string]bool{"CheckMemoryLoad": true, "CheckCpuLoad": false, "CheckLoadLoad": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := newPodPolicy(tt.pod)
+			for plugin, want := range tt.wantEnabled {
+				if got := policy.enabled(plugin); got != want {
+					t.Errorf("enabled(%v) = %v, 期望 %v", plugin, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricFor(t *testing.T) {
+	withTestConfig(t, &conf.Config{})
+
+	m := conf.MetricConfig{Name: "load", Threshold: 80, RecoverThreshold: 60}
+
+	tests := []struct {
+		name        string
+		pod         *v1.Pod
+		want        float64
+		wantRecover float64
+	}{
+		{name: "没有 pod", want: 80, wantRecover: 60},
+		{name: "没有 annotation", pod: policyPod("default", "", nil), want: 80, wantRecover: 60},
+		{
+			name:        "pod annotation 覆盖阈值并关闭滞后",
+			pod:         policyPod("default", "", map[string]string{conf.ThresholdAnnotation("load"): "95"}),
+			want:        95,
+			wantRecover: 0,
+		},
+		{
+			name:        "其它指标的 annotation 忽略",
+			pod:         policyPod("default", "", map[string]string{conf.ThresholdAnnotation("memory"): "95"}),
+			want:        80,
+			wantRecover: 60,
+		},
+		{
+			name:        "annotation 格式错误时使用指标配置",
+			pod:         policyPod("default", "", map[string]string{conf.ThresholdAnnotation("load"): "95%"}),
+			want:        80,
+			wantRecover: 60,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := metricFor(m, tt.pod, nil, "node-1")
+			if got.Threshold != tt.want || got.RecoverThreshold != tt.wantRecover {
+				t.Errorf("metricFor() 阈值 = %v/%v, 期望 %v/%v", got.Threshold, got.RecoverThreshold, tt.want, tt.wantRecover)
+			}
+		})
+	}
+}
+
+func TestMetricForQoSAndNodeThreshold(t *testing.T) {
+	withTestConfig(t, &conf.Config{NodeThresholdAnnotations: true})
+
+	m := conf.MetricConfig{
+		Name: "memory", Comparison: conf.ComparisonAbove, Threshold: 80, RecoverThreshold: 60,
+		QoSThresholds: map[v1.PodQOSClass]float64{v1.PodQOSGuaranteed: 90, v1.PodQOSBestEffort: 70},
+	}
+	guaranteed := policyPod("default", "", nil)
+	guaranteed.Spec.Containers = []v1.Container{{Resources: resources(
+		map[v1.ResourceName]string{v1.ResourceCPU: "1", v1.ResourceMemory: "1Gi"},
+		map[v1.ResourceName]string{v1.ResourceCPU: "1", v1.ResourceMemory: "1Gi"},
+	)}}
+	bestEffort := policyPod("default", "", nil)
+
+	tests := []struct {
+		name        string
+		pod         *v1.Pod
+		annotations map[string]string
+		want        float64
+		wantRecover float64
+	}{
+		{name: "node 没有覆盖阈值时使用 QoS 阈值并保留滞后", pod: guaranteed, want: 90, wantRecover: 60},
+		{
+			name:        "node 阈值比 QoS 阈值严格",
+			pod:         guaranteed,
+			annotations: map[string]string{conf.ThresholdAnnotation("memory"): "75"},
+			want:        75,
+			wantRecover: 60,
+		},
+		{
+			name:        "QoS 阈值比 node 阈值严格",
+			pod:         guaranteed,
+			annotations: map[string]string{conf.ThresholdAnnotation("memory"): "95"},
+			want:        90,
+			wantRecover: 60,
+		},
+		{
+			name:        "QoS 阈值比 node 阈值严格时沿用 node 的低水位",
+			pod:         bestEffort,
+			annotations: map[string]string{conf.ThresholdAnnotation("memory"): "75", conf.RecoverThresholdAnnotation("memory"): "50"},
+			want:        70,
+			wantRecover: 50,
+		},
+		{
+			name:        "node 低水位越过 QoS 阈值时关闭滞后",
+			pod:         bestEffort,
+			annotations: map[string]string{conf.ThresholdAnnotation("memory"): "75", conf.RecoverThresholdAnnotation("memory"): "72"},
+			want:        70,
+			wantRecover: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Annotations: tt.annotations}}
+			got := metricFor(m, tt.pod, node, "node-1")
+			if got.Threshold != tt.want || got.RecoverThreshold != tt.wantRecover {
+				t.Errorf("metricFor() 阈值 = %v/%v, 期望 %v/%v", got.Threshold, got.RecoverThreshold, tt.want, tt.wantRecover)
+			}
+		})
+	}
+}
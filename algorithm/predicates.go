@@ -4,65 +4,61 @@ import (
 	"context"
 	"github.com/prometheus/common/log"
 	"kube-scheduler-extender/conf"
-	"kube-scheduler-extender/controller"
+	"kube-scheduler-extender/metrics"
 	"kube-scheduler-extender/util"
 	"strings"
 	"sync"
 
-	"time"
-
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/util/workqueue"
 	extender "k8s.io/kube-scheduler/extender/v1"
 )
 
-const (
-	// CheckMemoryLoadPred rejects a node if node memory load high
-	CheckMemoryLoadPred        = "CheckMemoryLoad"
-	CheckMemoryLoadPredFailMsg = "node memory load high"
-)
-
-var predicatesFuncs = map[string]FitPredicate{
-	CheckMemoryLoadPred: CheckMemoryLoadPredicate,
-}
-
-type FitPredicate func(pod *v1.Pod, node v1.Node, nodeName string) (bool, []string, error)
-
-// 预选算法 list
-var predicatesSorted = []string{CheckMemoryLoadPred}
+// FitPredicate 判断 pod 能否调度到 node, detail 只在 explain 时不为 nil
+type FitPredicate func(pod *v1.Pod, node v1.Node, nodeName string, detail *Detail) (bool, []string, error)
 
 // filter filters nodes according to predicates defined in this extender
 // it's webhooked to pkg/scheduler/core/generic_scheduler.go#findNodesThatFitPod()
 func Filter(args extender.ExtenderArgs) *extender.ExtenderFilterResult {
-	var node v1.Node
+	// 处理期间配置和算法不会被热加载替换
+	conf.Lock.RLock()
+	defer conf.Lock.RUnlock()
 
 	var filteredNodeNames []string
+	var filteredNodes []v1.Node
 	failedNodes := make(extender.FailedNodesMap)
 	pod := args.Pod
 
 	result := extender.ExtenderFilterResult{
-		NodeNames:   &filteredNodeNames,
 		FailedNodes: failedNodes,
 		Error:       "",
 	}
 
-	if args.NodeNames == nil {
-		log.Errorln("请查看policy配置,目前只支持 nodeCacheCapable: true")
-		result.Error = "请查看policy配置,目前只支持 nodeCacheCapable: true"
+	// nodeCacheCapable: true 时调度器只传递 nodeName 列表, false 时传递 node 详情, 按请求的格式返回结果
+	nodes, nodeNames := argsNodes(args)
+	if args.NodeNames != nil {
+		result.NodeNames = &filteredNodeNames
+	} else if args.Nodes != nil {
+		result.Nodes = &v1.NodeList{}
+	} else {
+		log.Errorln("请求中 NodeNames 和 Nodes 都为空")
+		result.Error = "请求中 NodeNames 和 Nodes 都为空"
 		return &result
 	}
 
-	numNodesToFind := len(*args.NodeNames)
+	numNodesToFind := len(nodeNames)
 
-	log.Debugf("pod %v/%v 调度算法前,node 数量: %v, node 详情: %v", pod.Name, pod.Namespace, len(*args.NodeNames), strings.Join(*args.NodeNames, ","))
+	log.Debugf("pod %v/%v 调度算法前,node 数量: %v, node 详情: %v", pod.Name, pod.Namespace, numNodesToFind, strings.Join(nodeNames, ","))
 
-	// 如果预选函数==0,直接返回所有节点
-	if len(predicatesSorted) == 0 {
-		log.Debugln("预选函数为空,跳过Filter,直接返回")
+	policy := newPodPolicy(pod)
+
+	// 如果预选函数==0,或者 pod 跳过负载算法,直接返回所有节点
+	if len(plugins.predicatesSorted) == 0 || policy.skip {
+		log.Debugln("预选函数为空或 pod 跳过负载算法,跳过Filter,直接返回")
 		result.NodeNames = args.NodeNames
+		result.Nodes = args.Nodes
 		return &result
 	} else {
-		// 调度器 args 可以传递有 node详情 或者 nodeName列表 ,podFitsOnNode 为了兼容参数都提供，但是只生效一个，为了效率，这里只传递nodeName列表
 		errCh := util.NewErrorChannel()
 		ctx, cancel := context.WithCancel(context.Background())
 
@@ -71,8 +67,8 @@ func Filter(args extender.ExtenderArgs) *extender.ExtenderFilterResult {
 		)
 
 		checkNode := func(i int) {
-			nodeName := (*args.NodeNames)[i]
-			fits, failReasons, err := podFitsOnNode(pod, node, nodeName)
+			nodeName := nodeNames[i]
+			fits, failReasons, err := podFitsOnNode(pod, nodes[i], nodeName, policy)
 
 			if err != nil {
 				errCh.SendErrorWithCancel(err, cancel)
@@ -82,6 +78,9 @@ func Filter(args extender.ExtenderArgs) *extender.ExtenderFilterResult {
 				// 并发安全
 				predicateResultLock.Lock()
 				filteredNodeNames = append(filteredNodeNames, nodeName)
+				if args.Nodes != nil {
+					filteredNodes = append(filteredNodes, nodes[i])
+				}
 				predicateResultLock.Unlock()
 			} else {
 				predicateResultLock.Lock()
@@ -98,20 +97,42 @@ func Filter(args extender.ExtenderArgs) *extender.ExtenderFilterResult {
 			result.Error = err.Error()
 			return &result
 		}
+		if result.Nodes != nil {
+			result.Nodes.Items = filteredNodes
+		}
 	}
 
-	log.Debugf("pod %v/%v 调度算法后,node 数量: %v, node 详情: %v", pod.Name, pod.Namespace, len(*result.NodeNames), strings.Join(*result.NodeNames, ","))
+	log.Debugf("pod %v/%v 调度算法后,node 数量: %v, node 详情: %v", pod.Name, pod.Namespace, len(filteredNodeNames), strings.Join(filteredNodeNames, ","))
 
 	return &result
 }
 
+// argsNodes 返回请求中的 node 详情和 node 名, nodeCacheCapable: true 时 node 详情为空对象
+func argsNodes(args extender.ExtenderArgs) ([]v1.Node, []string) {
+	if args.NodeNames != nil {
+		return make([]v1.Node, len(*args.NodeNames)), *args.NodeNames
+	}
+	if args.Nodes == nil {
+		return nil, nil
+	}
+
+	nodeNames := make([]string, 0, len(args.Nodes.Items))
+	for _, node := range args.Nodes.Items {
+		nodeNames = append(nodeNames, node.Name)
+	}
+	return args.Nodes.Items, nodeNames
+}
+
 // 对一个 node 进行预选算法 Filter
-func podFitsOnNode(pod *v1.Pod, node v1.Node, nodeName string) (bool, []string, error) {
+func podFitsOnNode(pod *v1.Pod, node v1.Node, nodeName string, policy podPolicy) (bool, []string, error) {
 	var failReasons []string
 	// 遍历预选算法,有一个失败则直接返回,不继续执行后续预选算法
-	for _, predicateKey := range predicatesSorted {
-		if predicate, exist := predicatesFuncs[predicateKey]; exist {
-			fit, failures, err := predicate(pod, node, nodeName)
+	for _, predicateKey := range plugins.predicatesSorted {
+		if !policy.enabled(predicateKey) {
+			continue
+		}
+		if predicate, exist := plugins.predicatesFuncs[predicateKey]; exist {
+			fit, failures, err := predicate(pod, node, nodeName, nil)
 
 			// 出错直接返回
 			if err != nil {
@@ -120,6 +141,7 @@ func podFitsOnNode(pod *v1.Pod, node v1.Node, nodeName string) (bool, []string,
 			}
 			// 预选失败，跳出循环
 			if !fit {
+				metrics.PredicateFailures.WithLabelValues(predicateKey).Inc()
 				failReasons = append(failReasons, failures...)
 				break
 			}
@@ -128,23 +150,3 @@ func podFitsOnNode(pod *v1.Pod, node v1.Node, nodeName string) (bool, []string,
 	}
 	return len(failReasons) == 0, failReasons, nil
 }
-
-func CheckMemoryLoadPredicate(pod *v1.Pod, node v1.Node, nodeName string) (bool, []string, error) {
-	//log.Debugf("开始并发执行预选 %v 算法,在 node %v 调度 pod %v/%v", CheckMemoryLoadPred, nodeName, pod.Name, pod.Namespace)
-	var failReasons []string
-
-	currentTime := time.Now()
-	controller.NodeInfo.Lock.RLock()
-	defer controller.NodeInfo.Lock.RUnlock()
-	if n, exist := controller.NodeInfo.NodeMem[nodeName]; exist {
-		// 节点内存大于调度阀值，并且检查时间小于节点数据失效时间,检查失败
-		if n.Value >= conf.Conf.PrometheusMemoryThreshold && currentTime.Sub(n.CheckTime) <= controller.NodeOverdueTime {
-			log.Infof("pod %v/%v 不能调度 node %v,当前node 内存使用率 %v%%", pod.Name, pod.Namespace, nodeName, n.Value)
-			failReasons = append(failReasons, CheckMemoryLoadPredFailMsg)
-			return false, failReasons, nil
-		}
-	}
-
-	return true, nil, nil
-
-}
@@ -0,0 +1,103 @@
+package algorithm
+
+import (
+	"github.com/prometheus/common/log"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	extender "k8s.io/kube-scheduler/extender/v1"
+)
+
+// ProcessPreemption 从调度器选出的候选 node 中去掉驱逐 victims 后负载仍然超过阈值的 node,
+// 避免驱逐了 pod 之后抢占者仍然被预选过滤, 跳过负载算法的 pod 保留所有候选 node
+// it's webhooked to pkg/scheduler/core/generic_scheduler.go#processPreemptionWithExtenders()
+func ProcessPreemption(args extender.ExtenderPreemptionArgs) *extender.ExtenderPreemptionResult {
+	conf.Lock.RLock()
+	defer conf.Lock.RUnlock()
+
+	pod := args.Pod
+	result := extender.ExtenderPreemptionResult{
+		NodeNameToMetaVictims: make(map[string]*extender.MetaVictims),
+	}
+	policy := newPodPolicy(pod)
+
+	// nodeCacheCapable: true 时调度器只传递 victims 的 uid,需要从 pod informer 缓存中查找
+	for nodeName, victims := range args.NodeNameToMetaVictims {
+		var pods []*v1.Pod
+		for _, p := range victims.Pods {
+			if victim, ok := controller.LookupPod(p.UID); ok {
+				pods = append(pods, victim)
+			} else {
+				log.Warnf("pod %v/%v 抢占 node %v, victim %v 不在 pod informer 缓存中,不计算其释放的资源", pod.Name, pod.Namespace, nodeName, p.UID)
+			}
+		}
+		if fitsAfterPreemption(pod, nodeName, pods, policy) {
+			result.NodeNameToMetaVictims[nodeName] = victims
+		}
+	}
+
+	for nodeName, victims := range args.NodeNameToVictims {
+		if !fitsAfterPreemption(pod, nodeName, victims.Pods, policy) {
+			continue
+		}
+		metaVictims := &extender.MetaVictims{
+			NumPDBViolations: victims.NumPDBViolations,
+		}
+		for _, p := range victims.Pods {
+			metaVictims.Pods = append(metaVictims.Pods, &extender.MetaPod{UID: string(p.UID)})
+		}
+		result.NodeNameToMetaVictims[nodeName] = metaVictims
+	}
+
+	log.Debugf("pod %v/%v 抢占候选 node 数量: %v, 过滤后: %v", pod.Name, pod.Namespace,
+		len(args.NodeNameToMetaVictims)+len(args.NodeNameToVictims), len(result.NodeNameToMetaVictims))
+
+	return &result
+}
+
+// fitsAfterPreemption 判断驱逐 victims 后 node 的各项指标是否低于阈值, 被高低水位过滤的 node 需要低于低水位,
+// victims 的内存、CPU 请求从内置 memory、cpu 指标中扣除,其他指标无法估算,按当前值判断
+func fitsAfterPreemption(pod *v1.Pod, nodeName string, victims []*v1.Pod, policy podPolicy) bool {
+	var memory, cpu int64
+	for _, victim := range victims {
+		m, c := controller.PodRequests(victim)
+		memory += m
+		cpu += c
+	}
+
+	currentTime := time.Now()
+	controller.NodeInfo.Lock.RLock()
+	defer controller.NodeInfo.Lock.RUnlock()
+	for _, m := range conf.Get().Metrics {
+		if _, active := plugins.predicatesFuncs[m.Plugin]; !active || !policy.enabled(m.Plugin) {
+			continue
+		}
+		m = metricFor(m, pod, nil, nodeName)
+		n, exist := controller.NodeInfo.Get(m.Name, nodeName)
+		if !exist || !n.Usable(currentTime) {
+			if conf.Get().Staleness.Policy == conf.StalePolicyReject {
+				log.Infof("pod %v/%v 不能抢占 node %v,node %v 数据过期或缺失", pod.Name, pod.Namespace, nodeName, m.Name)
+				return false
+			}
+			continue
+		}
+
+		released, err := controller.RequestsLoad(m.Name, nodeName, memory, cpu)
+		if err != nil {
+			log.Warnf("pod %v/%v 抢占 node %v,无法换算 victims 释放的 %v: %v, 按当前值判断", pod.Name, pod.Namespace, nodeName, m.Name, err)
+		}
+		value := n.Value + n.Reserved(m.Name, nodeName, currentTime) - released
+		if m.Exceeds(value) {
+			log.Infof("pod %v/%v 不能抢占 node %v,驱逐 %v 个 pod 后 node %v 指标值 %v, 阈值 %v", pod.Name, pod.Namespace, nodeName, len(victims), m.Name, formatValue(value), m.Threshold)
+			return false
+		}
+		// 开启滞后时,已经被过滤的 node 驱逐后需要回落到低水位以内, 否则预选仍然过滤
+		if m.Hysteresis() && n.Excluded && !m.Recovered(value) {
+			log.Infof("pod %v/%v 不能抢占 node %v,驱逐 %v 个 pod 后 node %v 指标值 %v, 尚未回落到低水位 %v", pod.Name, pod.Namespace, nodeName, len(victims), m.Name, formatValue(value), m.RecoverThreshold)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package algorithm
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"testing"
+	"time"
+)
+
+func TestFitsAfterPreemptionHysteresis(t *testing.T) {
+	// 自定义指标无法估算 victims 释放的资源,按当前值判断
+	m := conf.MetricConfig{Name: "load", Plugin: "CheckLoadLoad", Comparison: conf.ComparisonAbove, Threshold: 80, RecoverThreshold: 60}
+
+	tests := []struct {
+		name     string
+		metric   conf.MetricConfig
+		value    float64
+		excluded bool
+		want     bool
+	}{
+		{name: "低于高水位且未被过滤", metric: m, value: 70, want: true},
+		{name: "超过高水位", metric: m, value: 85, want: false},
+		{name: "被过滤且未回落到低水位", metric: m, value: 70, excluded: true, want: false},
+		{name: "被过滤且已回落到低水位", metric: m, value: 50, excluded: true, want: true},
+		{name: "未开启滞后时忽略过滤状态", metric: conf.MetricConfig{Name: m.Name, Plugin: m.Plugin, Comparison: m.Comparison, Threshold: m.Threshold}, value: 70, excluded: true, want: true},
+	}
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTestConfig(t, &conf.Config{
+				Metrics:   []conf.MetricConfig{tt.metric},
+				Intervals: conf.IntervalsConfig{Overdue: time.Minute},
+			})
+			plugins = &Plugins{predicatesFuncs: map[string]FitPredicate{tt.metric.Plugin: nil}}
+			controller.NodeInfo = &controller.Nodes{NodeMetrics: map[string]map[string]*controller.NodeMetric{
+				tt.metric.Name: {"node-1": {NodeName: "node-1", Value: tt.value, CheckTime: time.Now(), Excluded: tt.excluded}},
+			}}
+
+			if got := fitsAfterPreemption(pod, "node-1", nil, newPodPolicy(pod)); got != tt.want {
+				t.Errorf("fitsAfterPreemption() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
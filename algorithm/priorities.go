@@ -5,51 +5,41 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/util/workqueue"
 	extender "k8s.io/kube-scheduler/extender/v1"
-	"kube-scheduler-extender/controller"
+	"kube-scheduler-extender/conf"
+	"math"
 	"strings"
 	"sync"
 )
 
-const (
-	// CheckMemoryLoadPriority 优选算法名字
-	CheckMemoryLoadPriority = "CheckMemoryLoad"
-)
-
-var priorityFuncs = map[string]FitPriority{
-	CheckMemoryLoadPriority: CheckMemoryLoadPriorityMap,
-}
-
-type FitPriority func(pod *v1.Pod, node v1.Node, nodeName string) (extender.HostPriority, error)
-
-// 优选算法 list，list中的优选函数 一定 保存在 priorityFuncs 中
-var prioritySorted = []string{CheckMemoryLoadPriority}
+// FitPriority 返回节点的原始得分,越大越好,exist 为 false 表示没有可用数据.
+// 原始得分在候选节点之间 min-max 归一化到 [MinExtenderPriority, MaxExtenderPriority] 后再加权. detail 只在 explain 时不为 nil
+type FitPriority func(pod *v1.Pod, node v1.Node, nodeName string, detail *Detail) (value float64, exist bool, err error)
 
 // it's webhooked to pkg/scheduler/core/generic_scheduler.go#prioritizeNodes()
 // you can't see existing scores calculated so far by default scheduler
 // instead, scores output by this function will be added back to default scheduler
 func Prioritize(args extender.ExtenderArgs) *extender.HostPriorityList {
-
-	if args.NodeNames == nil {
-		log.Errorln("请查看policy配置,目前只支持 nodeCacheCapable: true,返回所有节点 Score: 1")
-		result := make(extender.HostPriorityList, 0, len(args.Nodes.Items))
-		for _, v := range args.Nodes.Items {
-			result = append(result, extender.HostPriority{
-				Host:  v.Name,
-				Score: 1,
-			})
+	conf.Lock.RLock()
+	defer conf.Lock.RUnlock()
+
+	nodes, nodeNames := argsNodes(args)
+	numNode := len(nodeNames)
+	log.Debugf("pod %v/%v 优选算法, node 节点: %v", args.Pod.Name, args.Pod.Namespace, strings.Join(nodeNames, ","))
+
+	// 去掉 pod 通过 annotation 关闭的优选算法
+	policy := newPodPolicy(args.Pod)
+	var priorities []string
+	for _, priorityKey := range plugins.prioritySorted {
+		if policy.enabled(priorityKey) {
+			priorities = append(priorities, priorityKey)
 		}
-
-		return &result
 	}
 
-	numNode := len(*args.NodeNames)
-	log.Debugf("pod %v/%v 优选算法, node 节点: %v", args.Pod.Name, args.Pod.Namespace, strings.Join(*args.NodeNames, ","))
-
 	// 优选算法为0,则直接返回所有节点，Score = 1
-	if len(prioritySorted) == 0 {
-		log.Debugln("优选函数为空,跳过Prioritize,所有节点Score为1")
+	if len(priorities) == 0 {
+		log.Debugln("优选函数为空或 pod 跳过负载算法,跳过Prioritize,所有节点Score为1")
 		result := make(extender.HostPriorityList, 0, numNode)
-		for _, v := range *args.NodeNames {
+		for _, v := range nodeNames {
 			result = append(result, extender.HostPriority{
 				Host:  v,
 				Score: 1,
@@ -70,96 +60,86 @@ func Prioritize(args extender.ExtenderArgs) *extender.HostPriorityList {
 		errs = append(errs, err.Error())
 	}
 
-	// 二位数组，index 是算法索引，value 是 extender.HostPriorityList，extender.HostPriorityList 中 index 是 *args.NodeNames中的 index，value 是 extender.HostPriority
-	results := make([]extender.HostPriorityList, len(predicatesSorted))
-	for i := range prioritySorted {
-		results[i] = make(extender.HostPriorityList, numNode)
+	// 二位数组，index 是算法索引，value 是各个 node 的原始得分，index 是 nodeNames 中的 index
+	values := make([][]float64, len(priorities))
+	exists := make([][]bool, len(priorities))
+	for i := range priorities {
+		values[i] = make([]float64, numNode)
+		exists[i] = make([]bool, numNode)
 	}
 
 	workqueue.ParallelizeUntil(nil, 16, numNode, func(index int) {
-		var node v1.Node
-		var pod *v1.Pod
-		nodeName := (*args.NodeNames)[index]
-		for i, priorityKey := range prioritySorted {
-			var err error
-
-			if priority, exist := priorityFuncs[priorityKey]; exist {
+		pod := args.Pod
+		node := nodes[index]
+		nodeName := nodeNames[index]
+		for i, priorityKey := range priorities {
+			// plugins.priorityFuncs没有查到对应算法，正常来说不存在这种情况,按没有数据处理
+			if priority, exist := plugins.priorityFuncs[priorityKey]; exist {
 				// 优选 Map 过程
-				results[i][index], err = priority(pod, node, nodeName)
-
+				var err error
+				values[i][index], exists[i][index], err = priority(pod, node, nodeName, nil)
 				if err != nil {
 					appendError(err)
-					results[i][index].Host = nodeName
-				}
-
-			} else {
-				// priorityFuncs没有查到对应算法，正常来说不存在这种情况
-				results[i][index] = extender.HostPriority{
-					Host:  nodeName,
-					Score: 0,
 				}
 			}
-
 		}
 	})
 
 	if len(errs) != 0 {
 		log.Error("优选过程出错:", errs)
 		result := make(extender.HostPriorityList, 0, numNode)
-		for _, name := range *args.NodeNames {
+		for _, name := range nodeNames {
 			result = append(result, extender.HostPriority{Host: name, Score: 0})
 		}
 		return &result
 	}
 
 	// Summarize all scores.
-	result := make(extender.HostPriorityList, 0, numNode)
-	for i, name := range *args.NodeNames {
-		result = append(result, extender.HostPriority{Host: name, Score: 0})
+	var totalWeight int64
+	for _, priorityKey := range priorities {
+		totalWeight += plugins.priorityWeights[priorityKey]
+	}
 
-		for j := range prioritySorted {
-			result[i].Score += results[j][i].Score
+	scores := make([]float64, numNode)
+	for i, priorityKey := range priorities {
+		weight := float64(plugins.priorityWeights[priorityKey])
+		for j, score := range normalize(values[i], exists[i]) {
+			scores[j] += score * weight
+			log.Debugf("优选算法 %v, node %v 原始得分 %v, 归一化得分 %v", priorityKey, nodeNames[j], formatValue(values[i][j]), formatValue(score))
 		}
 	}
 
-	numPriority := len(prioritySorted)
-
-	// Reduce 过程
-	for _, node := range result {
-		node.Score = node.Score / int64(numPriority)
-		log.Debugf("最终得分: %v/%v -> %v, Score: (%d)", args.Pod.Name, args.Pod.Namespace, node.Host, node.Score)
+	// Reduce 过程,按权重加权平均
+	result := make(extender.HostPriorityList, 0, numNode)
+	for i, name := range nodeNames {
+		result = append(result, extender.HostPriority{Host: name, Score: int64(math.Round(scores[i] / float64(totalWeight)))})
+		log.Debugf("最终得分: %v/%v -> %v, Score: (%d)", args.Pod.Name, args.Pod.Namespace, name, result[i].Score)
 	}
 
 	return &result
 
 }
 
-func CheckMemoryLoadPriorityMap(pod *v1.Pod, node v1.Node, nodeName string) (extender.HostPriority, error) {
-	//log.Debugf("开始执行优选 %v 算法,计算 node %v 得分", CheckMemoryLoadPriority, nodeName)
-	var score int64
-
-	controller.NodeInfo.Lock.RLock()
-	defer controller.NodeInfo.Lock.RUnlock()
-	if n, exist := controller.NodeInfo.NodeMem[nodeName]; exist {
-		score = int64((100 - n.Value) / 10)
+// normalize 把有数据的节点的原始得分 min-max 归一化到 [MinExtenderPriority, MaxExtenderPriority],
+// 即使负载差异很小也能区分节点. 得分都相同时为 MaxExtenderPriority,没有数据的节点为 MinExtenderPriority
+func normalize(values []float64, exists []bool) []float64 {
+	min, max := math.Inf(1), math.Inf(-1)
+	for i, v := range values {
+		if exists[i] {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+	}
 
+	scores := make([]float64, len(values))
+	for i, v := range values {
 		switch {
-		case score >= extender.MaxExtenderPriority:
-			score = extender.MaxExtenderPriority
-		case score <= extender.MinExtenderPriority:
-			score = extender.MinExtenderPriority
+		case !exists[i]:
+			scores[i] = float64(extender.MinExtenderPriority)
+		case max == min:
+			scores[i] = float64(extender.MaxExtenderPriority)
+		default:
+			scores[i] = float64(extender.MinExtenderPriority) + (v-min)/(max-min)*float64(extender.MaxExtenderPriority-extender.MinExtenderPriority)
 		}
-
-		log.Debugf("执行优选算法 %v,node %v,设置 Score 为 %v", CheckMemoryLoadPriority, nodeName, score)
-
-	} else {
-		log.Debugf("执行优选算法 %v,node %v 缓存未命中,设置 Score 为 1", CheckMemoryLoadPriority, nodeName)
-		score = 1
 	}
-
-	return extender.HostPriority{
-		Host:  nodeName,
-		Score: score,
-	}, nil
-
+	return scores
 }
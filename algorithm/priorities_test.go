@@ -0,0 +1,128 @@
+package algorithm
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	extender "k8s.io/kube-scheduler/extender/v1"
+	"kube-scheduler-extender/conf"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		exists []bool
+		want   []float64
+	}{
+		{
+			name:   "线性映射到 [0, 10]",
+			values: []float64{0.2, 0.4, 0.3},
+			exists: []bool{true, true, true},
+			want:   []float64{0, 10, 5},
+		},
+		{
+			name:   "差异很小也能区分",
+			values: []float64{0.501, 0.502},
+			exists: []bool{true, true},
+			want:   []float64{0, 10},
+		},
+		{
+			name:   "得分相同时都为最高分",
+			values: []float64{0.7, 0.7},
+			exists: []bool{true, true},
+			want:   []float64{10, 10},
+		},
+		{
+			name:   "没有数据的节点为最低分且不参与归一化",
+			values: []float64{0.9, 0.5, 0.1},
+			exists: []bool{true, true, false},
+			want:   []float64{10, 0, 0},
+		},
+		{
+			name:   "都没有数据",
+			values: []float64{0, 0},
+			exists: []bool{false, false},
+			want:   []float64{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalize(tt.values, tt.exists)
+			if len(got) != len(tt.want) {
+				t.Fatalf("normalize() = %v, 期望 %v", got, tt.want)
+			}
+			for i := range got {
+				if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+					t.Fatalf("normalize() = %v, 期望 %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// fixedPriority 按 node 名返回固定原始得分,不在 values 中的 node 没有数据
+func fixedPriority(values map[string]float64) FitPriority {
+	return func(pod *v1.Pod, node v1.Node, nodeName string, detail *Detail) (float64, bool, error) {
+		value, exist := values[nodeName]
+		return value, exist, nil
+	}
+}
+
+func TestPrioritizeWeights(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterPriority("a", staticPriority(fixedPriority(map[string]float64{"node-1": 0, "node-2": 1, "node-3": 0.5})), 1)
+	r.RegisterPriority("b", staticPriority(fixedPriority(map[string]float64{"node-1": 1, "node-2": 0})), 1)
+
+	tests := []struct {
+		name       string
+		priorities []conf.PluginConfig
+		want       []int64
+	}{
+		{
+			name: "默认权重",
+			// a: 0, 10, 5; b: 10, 0, 0
+			want: []int64{5, 5, 3},
+		},
+		{
+			name:       "按权重加权平均",
+			priorities: []conf.PluginConfig{{Name: "a", Weight: 1}, {Name: "b", Weight: 3}},
+			// (0*1 + 10*3) / 4, (10*1 + 0*3) / 4, (5*1 + 0*3) / 4
+			want: []int64{8, 3, 1},
+		},
+		{
+			name:       "只启用一个算法",
+			priorities: []conf.PluginConfig{{Name: "a", Weight: 2}},
+			want:       []int64{0, 10, 5},
+		},
+	}
+
+	withTestConfig(t, &conf.Config{})
+
+	nodeNames := []string{"node-1", "node-2", "node-3"}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := r.Enable(conf.PluginsConfig{Priorities: tt.priorities})
+			if err != nil {
+				t.Fatal(err)
+			}
+			plugins = p
+
+			result := Prioritize(extender.ExtenderArgs{Pod: pod, NodeNames: &nodeNames})
+			got := make([]int64, 0, len(*result))
+			for i, host := range *result {
+				if host.Host != nodeNames[i] {
+					t.Fatalf("第 %v 个结果为 node %v, 期望 %v", i, host.Host, nodeNames[i])
+				}
+				got = append(got, host.Score)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Prioritize() 得分 %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
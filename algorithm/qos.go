@@ -0,0 +1,57 @@
+package algorithm
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// podQOSClass 按 pod 所有容器(包括 init container)的 cpu、memory requests 和 limits 计算 QoS class,
+// 与 kubelet 的规则一致: 都没有设置为 BestEffort, 每个容器都设置了 limits 且 requests 等于 limits 为 Guaranteed
+func podQOSClass(pod *v1.Pod) v1.PodQOSClass {
+	requests := make(v1.ResourceList)
+	limits := make(v1.ResourceList)
+	guaranteed := true
+	containers := make([]v1.Container, 0, len(pod.Spec.Containers)+len(pod.Spec.InitContainers))
+	containers = append(containers, pod.Spec.Containers...)
+	containers = append(containers, pod.Spec.InitContainers...)
+	for _, c := range containers {
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			limit, hasLimit := c.Resources.Limits[name]
+			// 与 API server 的默认值一致,只设置了 limits 时 requests 等于 limits
+			request, hasRequest := c.Resources.Requests[name]
+			if !hasRequest && hasLimit {
+				request = limit
+			}
+			if !request.IsZero() {
+				addQuantity(requests, name, request)
+			}
+			if hasLimit && !limit.IsZero() {
+				addQuantity(limits, name, limit)
+			} else {
+				guaranteed = false
+			}
+		}
+	}
+
+	if len(requests) == 0 && len(limits) == 0 {
+		return v1.PodQOSBestEffort
+	}
+	if guaranteed && len(requests) == len(limits) {
+		for name, request := range requests {
+			if limit := limits[name]; limit.Cmp(request) != 0 {
+				return v1.PodQOSBurstable
+			}
+		}
+		return v1.PodQOSGuaranteed
+	}
+	return v1.PodQOSBurstable
+}
+
+func addQuantity(list v1.ResourceList, name v1.ResourceName, q resource.Quantity) {
+	if total, exist := list[name]; exist {
+		total.Add(q)
+		list[name] = total
+		return
+	}
+	list[name] = q.DeepCopy()
+}
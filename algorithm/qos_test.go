@@ -0,0 +1,74 @@
+package algorithm
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"testing"
+)
+
+func resources(requests, limits map[v1.ResourceName]string) v1.ResourceRequirements {
+	r := v1.ResourceRequirements{Requests: v1.ResourceList{}, Limits: v1.ResourceList{}}
+	for name, q := range requests {
+		r.Requests[name] = resource.MustParse(q)
+	}
+	for name, q := range limits {
+		r.Limits[name] = resource.MustParse(q)
+	}
+	return r
+}
+
+func TestPodQOSClass(t *testing.T) {
+	guaranteed := resources(
+		map[v1.ResourceName]string{v1.ResourceCPU: "1", v1.ResourceMemory: "1Gi"},
+		map[v1.ResourceName]string{v1.ResourceCPU: "1", v1.ResourceMemory: "1Gi"},
+	)
+
+	tests := []struct {
+		name           string
+		containers     []v1.ResourceRequirements
+		initContainers []v1.ResourceRequirements
+		want           v1.PodQOSClass
+	}{
+		{name: "没有 requests 和 limits", containers: []v1.ResourceRequirements{{}}, want: v1.PodQOSBestEffort},
+		{name: "requests 等于 limits", containers: []v1.ResourceRequirements{guaranteed}, want: v1.PodQOSGuaranteed},
+		{name: "只设置 limits", containers: []v1.ResourceRequirements{resources(nil, map[v1.ResourceName]string{v1.ResourceCPU: "1", v1.ResourceMemory: "1Gi"})}, want: v1.PodQOSGuaranteed},
+		{name: "requests 小于 limits", containers: []v1.ResourceRequirements{resources(
+			map[v1.ResourceName]string{v1.ResourceCPU: "500m", v1.ResourceMemory: "1Gi"},
+			map[v1.ResourceName]string{v1.ResourceCPU: "1", v1.ResourceMemory: "1Gi"},
+		)}, want: v1.PodQOSBurstable},
+		{name: "只设置 memory", containers: []v1.ResourceRequirements{resources(nil, map[v1.ResourceName]string{v1.ResourceMemory: "1Gi"})}, want: v1.PodQOSBurstable},
+		{
+			name:           "init container 没有设置 limits",
+			containers:     []v1.ResourceRequirements{guaranteed},
+			initContainers: []v1.ResourceRequirements{resources(map[v1.ResourceName]string{v1.ResourceCPU: "100m"}, nil)},
+			want:           v1.PodQOSBurstable,
+		},
+		{
+			name:           "init container 也是 Guaranteed",
+			containers:     []v1.ResourceRequirements{guaranteed},
+			initContainers: []v1.ResourceRequirements{guaranteed},
+			want:           v1.PodQOSGuaranteed,
+		},
+		{
+			name:           "只有 init container 设置 requests",
+			containers:     []v1.ResourceRequirements{{}},
+			initContainers: []v1.ResourceRequirements{resources(map[v1.ResourceName]string{v1.ResourceMemory: "64Mi"}, nil)},
+			want:           v1.PodQOSBurstable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v1.Pod{}
+			for _, r := range tt.containers {
+				pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{Resources: r})
+			}
+			for _, r := range tt.initContainers {
+				pod.Spec.InitContainers = append(pod.Spec.InitContainers, v1.Container{Resources: r})
+			}
+			if got := podQOSClass(pod); got != tt.want {
+				t.Errorf("podQOSClass() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
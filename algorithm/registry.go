@@ -0,0 +1,202 @@
+package algorithm
+
+import (
+	"errors"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"kube-scheduler-extender/conf"
+)
+
+// plugins 当前生效的预选和优选算法,与 conf.Get() 一起在 conf.Lock 的写锁下替换
+var plugins = &Plugins{}
+
+// PredicateFactory 按插件配置中的参数创建预选算法,参数由算法自己解码
+type PredicateFactory func(args conf.PluginArgs) (FitPredicate, error)
+
+// PriorityFactory 按插件配置中的参数创建优选算法,参数由算法自己解码
+type PriorityFactory func(args conf.PluginArgs) (FitPriority, error)
+
+// Provider 按配置向 Registry 注册算法,例如为每个指标注册一组算法
+type Provider func(r *Registry, c *conf.Config) error
+
+type namedProvider struct {
+	name     string
+	provider Provider
+}
+
+// providers 通过 Register 注册的 provider,NewPlugins 按注册顺序调用
+var providers []namedProvider
+
+// Register 注册算法 provider,在 init 中调用,名字重复时 panic.
+// 新增算法只需要在自己的文件中注册,不需要修改 NewPlugins
+func Register(name string, provider Provider) {
+	for _, p := range providers {
+		if p.name == name {
+			panic(fmt.Sprintf("算法 provider %v 重复注册", name))
+		}
+	}
+	providers = append(providers, namedProvider{name: name, provider: provider})
+}
+
+// Registry 按名字注册的算法,按注册顺序作为默认执行顺序
+type Registry struct {
+	predicates     map[string]PredicateFactory
+	predicateNames []string
+
+	priorities     map[string]PriorityFactory
+	priorityNames  []string
+	defaultWeights map[string]int64
+}
+
+// Plugins 按配置从 Registry 中选出的预选和优选算法
+type Plugins struct {
+	predicatesFuncs  map[string]FitPredicate
+	predicatesSorted []string
+
+	priorityFuncs   map[string]FitPriority
+	priorityWeights map[string]int64
+	prioritySorted  []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		predicates:     make(map[string]PredicateFactory),
+		priorities:     make(map[string]PriorityFactory),
+		defaultWeights: make(map[string]int64),
+	}
+}
+
+// RegisterPredicate 注册预选算法,名字不能重复
+func (r *Registry) RegisterPredicate(name string, predicate PredicateFactory) error {
+	if _, exist := r.predicates[name]; exist {
+		return fmt.Errorf("预选算法 %v 重复注册", name)
+	}
+	r.predicates[name] = predicate
+	r.predicateNames = append(r.predicateNames, name)
+	return nil
+}
+
+// RegisterPriority 注册优选算法,weight 为没有在配置中指定权重时使用的默认权重
+func (r *Registry) RegisterPriority(name string, priority PriorityFactory, weight int64) error {
+	if _, exist := r.priorities[name]; exist {
+		return fmt.Errorf("优选算法 %v 重复注册", name)
+	}
+	r.priorities[name] = priority
+	r.priorityNames = append(r.priorityNames, name)
+	r.defaultWeights[name] = weight
+	return nil
+}
+
+// NewPlugins 按配置调用所有 provider 注册算法,并按配置选出生效的算法,
+// 配置了未注册的算法或者算法参数错误时返回错误. 不修改当前生效的算法,由 SetPlugins 替换
+func NewPlugins(c *conf.Config) (*Plugins, error) {
+	r := NewRegistry()
+	for _, p := range providers {
+		if err := p.provider(r, c); err != nil {
+			return nil, fmt.Errorf("注册 %v 算法出错: %v", p.name, err)
+		}
+	}
+	return r.Enable(c.Plugins)
+}
+
+// staticPredicate 没有参数的预选算法,配置了参数时返回错误
+func staticPredicate(predicate FitPredicate) PredicateFactory {
+	return func(args conf.PluginArgs) (FitPredicate, error) {
+		if len(args) != 0 {
+			return nil, errors.New("不支持参数")
+		}
+		return predicate, nil
+	}
+}
+
+// staticPriority 没有参数的优选算法,配置了参数时返回错误
+func staticPriority(priority FitPriority) PriorityFactory {
+	return func(args conf.PluginArgs) (FitPriority, error) {
+		if len(args) != 0 {
+			return nil, errors.New("不支持参数")
+		}
+		return priority, nil
+	}
+}
+
+// SetPlugins 替换当前生效的算法,调用方需持有 conf.Lock 的写锁
+func SetPlugins(p *Plugins) {
+	plugins = p
+	log.Infof("生效的预选算法: %v", p.predicatesSorted)
+	log.Infof("生效的优选算法: %v, 权重: %v", p.prioritySorted, p.priorityWeights)
+}
+
+// Enable 按配置选出生效的预选和优选算法及其顺序、权重,并用配置的参数创建算法,
+// 没有配置时使用所有注册的算法,配置了未注册的算法或者参数错误时返回错误
+func (r *Registry) Enable(c conf.PluginsConfig) (*Plugins, error) {
+	predicates := r.predicateNames
+	args := make(map[string]conf.PluginArgs)
+	if c.Predicates != nil {
+		predicates = nil
+		for _, p := range c.Predicates {
+			if _, exist := r.predicates[p.Name]; !exist {
+				return nil, fmt.Errorf("未知的预选算法 %v, 已注册: %v", p.Name, r.predicateNames)
+			}
+			predicates = append(predicates, p.Name)
+			args[p.Name] = p.Args
+		}
+	}
+
+	priorities := r.priorityNames
+	weights := r.defaultWeights
+	priorityArgs := make(map[string]conf.PluginArgs)
+	if c.Priorities != nil {
+		priorities = nil
+		weights = make(map[string]int64)
+		for _, p := range c.Priorities {
+			if _, exist := r.priorities[p.Name]; !exist {
+				return nil, fmt.Errorf("未知的优选算法 %v, 已注册: %v", p.Name, r.priorityNames)
+			}
+			priorities = append(priorities, p.Name)
+			priorityArgs[p.Name] = p.Args
+			weights[p.Name] = r.defaultWeights[p.Name]
+			if p.Weight > 0 {
+				weights[p.Name] = p.Weight
+			}
+		}
+	}
+
+	p := &Plugins{
+		predicatesFuncs:  make(map[string]FitPredicate),
+		predicatesSorted: predicates,
+		priorityFuncs:    make(map[string]FitPriority),
+		priorityWeights:  make(map[string]int64),
+		prioritySorted:   priorities,
+	}
+	for _, name := range predicates {
+		predicate, err := r.predicates[name](args[name])
+		if err != nil {
+			return nil, fmt.Errorf("预选算法 %v 参数错误: %v", name, err)
+		}
+		p.predicatesFuncs[name] = predicate
+	}
+	for _, name := range priorities {
+		priority, err := r.priorities[name](priorityArgs[name])
+		if err != nil {
+			return nil, fmt.Errorf("优选算法 %v 参数错误: %v", name, err)
+		}
+		p.priorityFuncs[name] = priority
+		p.priorityWeights[name] = weights[name]
+	}
+	return p, nil
+}
+
+// ActivePlugins 返回当前生效的预选算法顺序和优选算法权重,调用方需持有 conf.Lock 的读锁
+func ActivePlugins() conf.PluginsConfig {
+	active := conf.PluginsConfig{
+		Predicates: make([]conf.PluginConfig, 0, len(plugins.predicatesSorted)),
+		Priorities: make([]conf.PluginConfig, 0, len(plugins.prioritySorted)),
+	}
+	for _, name := range plugins.predicatesSorted {
+		active.Predicates = append(active.Predicates, conf.PluginConfig{Name: name})
+	}
+	for _, name := range plugins.prioritySorted {
+		active.Priorities = append(active.Priorities, conf.PluginConfig{Name: name, Weight: plugins.priorityWeights[name]})
+	}
+	return active
+}
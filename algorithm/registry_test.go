@@ -0,0 +1,119 @@
+package algorithm
+
+import (
+	"kube-scheduler-extender/conf"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegistryEnable(t *testing.T) {
+	m := conf.MetricConfig{Name: conf.MemoryMetricName, Plugin: "CheckMemoryLoad", Threshold: 80}
+	r := NewRegistry()
+	r.RegisterPredicate(m.Plugin, staticPredicate(newLoadPredicate(m)))
+	r.RegisterPredicate(MemoryFitPlugin, memoryFitFactory(m, conf.MemoryFitRequests))
+	r.RegisterPriority(m.Plugin, staticPriority(newLoadPriority(m)), 2)
+
+	tests := []struct {
+		name           string
+		config         conf.PluginsConfig
+		wantPredicates []string
+		wantWeights    map[string]int64
+		wantErr        string
+	}{
+		{
+			name:           "没有配置时使用所有注册的算法",
+			wantPredicates: []string{m.Plugin, MemoryFitPlugin},
+			wantWeights:    map[string]int64{m.Plugin: 2},
+		},
+		{
+			name: "按配置的顺序和权重",
+			config: conf.PluginsConfig{
+				Predicates: []conf.PluginConfig{{Name: MemoryFitPlugin}, {Name: m.Plugin}},
+				Priorities: []conf.PluginConfig{{Name: m.Plugin, Weight: 5}},
+			},
+			wantPredicates: []string{MemoryFitPlugin, m.Plugin},
+			wantWeights:    map[string]int64{m.Plugin: 5},
+		},
+		{
+			name:        "预选配置为空列表时不执行预选,没有配置权重时使用默认权重",
+			config:      conf.PluginsConfig{Predicates: []conf.PluginConfig{}, Priorities: []conf.PluginConfig{{Name: m.Plugin}}},
+			wantWeights: map[string]int64{m.Plugin: 2},
+		},
+		{
+			name: "memory fit 参数",
+			config: conf.PluginsConfig{Predicates: []conf.PluginConfig{
+				{Name: MemoryFitPlugin, Args: conf.PluginArgs{"basis": conf.MemoryFitLimits}},
+			}},
+			wantPredicates: []string{MemoryFitPlugin},
+			wantWeights:    map[string]int64{m.Plugin: 2},
+		},
+		{
+			name:    "未注册的预选算法",
+			config:  conf.PluginsConfig{Predicates: []conf.PluginConfig{{Name: "CheckDiskLoad"}}},
+			wantErr: "未知的预选算法 CheckDiskLoad",
+		},
+		{
+			name:    "未注册的优选算法",
+			config:  conf.PluginsConfig{Priorities: []conf.PluginConfig{{Name: "CheckDiskLoad"}}},
+			wantErr: "未知的优选算法 CheckDiskLoad",
+		},
+		{
+			name: "没有参数的算法配置了参数",
+			config: conf.PluginsConfig{Predicates: []conf.PluginConfig{
+				{Name: m.Plugin, Args: conf.PluginArgs{"threshold": 90}},
+			}},
+			wantErr: "预选算法 CheckMemoryLoad 参数错误: 不支持参数",
+		},
+		{
+			name: "memory fit 参数值不合法",
+			config: conf.PluginsConfig{Predicates: []conf.PluginConfig{
+				{Name: MemoryFitPlugin, Args: conf.PluginArgs{"basis": "usage"}},
+			}},
+			wantErr: "预选算法 CheckMemoryFit 参数错误: basis 只支持",
+		},
+		{
+			name: "memory fit 未知参数",
+			config: conf.PluginsConfig{Predicates: []conf.PluginConfig{
+				{Name: MemoryFitPlugin, Args: conf.PluginArgs{"size": "1Gi"}},
+			}},
+			wantErr: "预选算法 CheckMemoryFit 参数错误",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := r.Enable(tt.config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Enable() error = %v, 期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(p.predicatesSorted, tt.wantPredicates) {
+				t.Errorf("预选算法 %v, 期望 %v", p.predicatesSorted, tt.wantPredicates)
+			}
+			if !reflect.DeepEqual(p.priorityWeights, tt.wantWeights) {
+				t.Errorf("优选权重 %v, 期望 %v", p.priorityWeights, tt.wantWeights)
+			}
+			for _, name := range p.predicatesSorted {
+				if p.predicatesFuncs[name] == nil {
+					t.Errorf("预选算法 %v 没有创建", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("重复注册 provider 期望 panic")
+		}
+	}()
+	// metrics 已经在 init 中注册
+	Register("metrics", RegisterMetricPlugins)
+}
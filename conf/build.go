@@ -0,0 +1,161 @@
+package conf
+
+import (
+	"time"
+)
+
+// Options 启动参数,配置文件中的同名配置优先
+type Options struct {
+	DataSource        string
+	DataSourceTimeout time.Duration
+
+	PrometheusUrl           string
+	PrometheusMode          string
+	PrometheusMergeStrategy string
+	LogRequestBody          bool
+
+	PrometheusBearerToken        string
+	PrometheusBearerTokenFile    string
+	PrometheusBasicAuthUsername  string
+	PrometheusBasicAuthPassword  string
+	PrometheusCAFile             string
+	PrometheusCertFile           string
+	PrometheusKeyFile            string
+	PrometheusInsecureSkipVerify bool
+	PrometheusHeaders            map[string]string
+
+	KubeletAccess      string
+	KubeletPort        int
+	KubeletInsecureTLS bool
+	KubeletWorkers     int
+
+	MemoryMetrics             string
+	MemoryThreshold           int
+	MemoryRecoverThreshold    int
+	MemoryGuaranteedThreshold int
+	MemoryBurstableThreshold  int
+	MemoryBestEffortThreshold int
+	MemoryCapacityMetrics     string
+	CPUMetrics                string
+	CPUThreshold              int
+	CPURecoverThreshold       int
+
+	MetricsConfigFile string
+	PluginsConfigFile string
+	// ConfigFile 结构化配置文件,覆盖以上启动参数
+	ConfigFile string
+
+	NodeLabel                string
+	NodeNameRegex            string
+	NodeNameReplacement      string
+	NodeLookup               bool
+	NodeThresholdAnnotations bool
+
+	StalePolicy      string
+	StaleGracePeriod time.Duration
+	ReservationTTL   time.Duration
+	// ReservationSampleLag 样本时间之前仍然计算预留的时间
+	ReservationSampleLag time.Duration
+	MemoryFit            bool
+	MemoryFitBasis       string
+
+	BypassNamespaces      string
+	BypassPriorityClasses string
+
+	RefreshInterval  time.Duration
+	FlushInterval    time.Duration
+	OverdueTime      time.Duration
+	IntervalJitter   float64
+	BackoffMax       time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// Build 按启动参数和配置文件生成配置,不修改当前配置. 启动和热加载使用同样的过程
+func Build(o Options) (*Config, error) {
+	var file *File
+	if o.ConfigFile != "" {
+		f, err := loadFile(o.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		f.override(&o)
+		file = f
+	}
+
+	c := newConfig(o.PrometheusUrl, o.MemoryMetrics, o.MemoryThreshold, o.CPUMetrics, o.CPUThreshold, o.LogRequestBody)
+	if err := c.loadMetricsConfig(o.MetricsConfigFile); err != nil {
+		return nil, err
+	}
+	if err := c.loadPluginsConfig(o.PluginsConfigFile); err != nil {
+		return nil, err
+	}
+	if file != nil {
+		if err := c.mergeMetrics(file.Metrics); err != nil {
+			return nil, err
+		}
+		if file.Plugins != nil {
+			if err := file.Plugins.validate(); err != nil {
+				return nil, err
+			}
+			c.Plugins = *file.Plugins
+		}
+	}
+	if err := c.setRecoverThresholds(o.MemoryRecoverThreshold, o.CPURecoverThreshold); err != nil {
+		return nil, err
+	}
+	c.setQoSThresholds(o.MemoryGuaranteedThreshold, o.MemoryBurstableThreshold, o.MemoryBestEffortThreshold)
+
+	if err := c.setDataSource(DataSourceConfig{
+		Type:    o.DataSource,
+		Timeout: o.DataSourceTimeout,
+		Prometheus: PrometheusClientConfig{
+			BearerToken:        o.PrometheusBearerToken,
+			BearerTokenFile:    o.PrometheusBearerTokenFile,
+			BasicAuthUsername:  o.PrometheusBasicAuthUsername,
+			BasicAuthPassword:  o.PrometheusBasicAuthPassword,
+			CAFile:             o.PrometheusCAFile,
+			CertFile:           o.PrometheusCertFile,
+			KeyFile:            o.PrometheusKeyFile,
+			InsecureSkipVerify: o.PrometheusInsecureSkipVerify,
+			Headers:            o.PrometheusHeaders,
+		},
+		Kubelet: KubeletConfig{
+			Access:                o.KubeletAccess,
+			Port:                  o.KubeletPort,
+			InsecureSkipTLSVerify: o.KubeletInsecureTLS,
+			Workers:               o.KubeletWorkers,
+		},
+	}); err != nil {
+		return nil, err
+	}
+	if err := c.setPrometheusHA(o.PrometheusMode, o.PrometheusMergeStrategy); err != nil {
+		return nil, err
+	}
+	if err := c.setStalePolicy(o.StalePolicy, o.StaleGracePeriod); err != nil {
+		return nil, err
+	}
+	if err := c.setReservation(o.ReservationTTL, o.ReservationSampleLag); err != nil {
+		return nil, err
+	}
+	if err := c.setNodeNameMapping(o.NodeLabel, o.NodeNameRegex, o.NodeNameReplacement, o.NodeLookup); err != nil {
+		return nil, err
+	}
+	c.setNodeThresholdAnnotations(o.NodeThresholdAnnotations)
+	c.setPodBypass(o.BypassNamespaces, o.BypassPriorityClasses)
+	if err := c.setMemoryFit(o.MemoryFit, o.MemoryFitBasis, o.MemoryCapacityMetrics); err != nil {
+		return nil, err
+	}
+	if err := c.setIntervals(IntervalsConfig{
+		Refresh:          o.RefreshInterval,
+		Flush:            o.FlushInterval,
+		Overdue:          o.OverdueTime,
+		Jitter:           o.IntervalJitter,
+		BackoffMax:       o.BackoffMax,
+		BreakerThreshold: o.BreakerThreshold,
+		BreakerCooldown:  o.BreakerCooldown,
+	}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
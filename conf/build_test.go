@@ -0,0 +1,241 @@
+package conf
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testOptions 与启动参数的默认值一致
+func testOptions() Options {
+	return Options{
+		DataSource:              PrometheusDataSource,
+		DataSourceTimeout:       30 * time.Second,
+		PrometheusUrl:           "http://127.0.0.1:9090",
+		PrometheusMode:          PrometheusModeFailover,
+		PrometheusMergeStrategy: MergeStrategyWorst,
+		KubeletAccess:           KubeletAccessProxy,
+		KubeletPort:             10250,
+		KubeletWorkers:          16,
+		MemoryMetrics:           "HostMemoryUsagePercent",
+		MemoryThreshold:         80,
+		MemoryCapacityMetrics:   "HostMemoryTotalBytes",
+		CPUMetrics:              "HostCPUUsagePercent",
+		CPUThreshold:            80,
+		NodeLabel:               "instance",
+		NodeNameReplacement:     "$1",
+		StalePolicy:             StalePolicyAllow,
+		StaleGracePeriod:        5 * time.Minute,
+		MemoryFitBasis:          MemoryFitRequests,
+		ReservationSampleLag:    30 * time.Second,
+		RefreshInterval:         60 * time.Second,
+		FlushInterval:           30 * time.Second,
+		OverdueTime:             180 * time.Second,
+		IntervalJitter:          0.1,
+		BackoffMax:              5 * time.Minute,
+		BreakerThreshold:        5,
+		BreakerCooldown:         5 * time.Minute,
+	}
+}
+
+// writeFile 在临时目录写入配置文件,返回路径
+func writeFile(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name string
+		// file 配置文件内容,为空时只使用启动参数
+		file    string
+		check   func(t *testing.T, c *Config)
+		wantErr string
+	}{
+		{
+			name: "只使用启动参数",
+			check: func(t *testing.T, c *Config) {
+				if c.DataSource.Type != PrometheusDataSource || c.DataSource.Timeout != 30*time.Second || c.DataSource.Kubelet.Workers != 16 {
+					t.Errorf("DataSource = %+v", c.DataSource)
+				}
+				if !reflect.DeepEqual(c.PrometheusUrls, []string{"http://127.0.0.1:9090"}) {
+					t.Errorf("PrometheusUrls = %v", c.PrometheusUrls)
+				}
+				if m := c.Metrics[indexMetric(c.Metrics, MemoryMetricName)]; m.Threshold != 80 {
+					t.Errorf("memory 阈值 %v, 期望 80", m.Threshold)
+				}
+				if c.Staleness.Policy != StalePolicyAllow || c.NodeName.Label != "instance" {
+					t.Errorf("Staleness = %+v, NodeName = %+v", c.Staleness, c.NodeName)
+				}
+			},
+		},
+		{
+			name: "配置文件覆盖启动参数",
+			file: `
+version: v1
+dataSource:
+  type: kubelet
+  timeout: 10s
+  prometheus:
+    urls: [http://a:9090, http://b:9090]
+    mode: merge
+    bearerToken: token
+    tls:
+      insecureSkipVerify: true
+    headers:
+      X-Scope-OrgID: tenant
+  kubelet:
+    access: direct
+    port: 10255
+    workers: 4
+  nodeName:
+    label: node
+thresholds:
+  memory: 70
+  cpuRecover: 60
+staleness:
+  policy: reject
+intervals:
+  refresh: 30s
+reservation:
+  ttl: 2m
+  sampleLag: 10s
+bypass:
+  namespaces: [kube-system]
+`,
+			check: func(t *testing.T, c *Config) {
+				want := DataSourceConfig{
+					Type:    KubeletDataSource,
+					Timeout: 10 * time.Second,
+					Prometheus: PrometheusClientConfig{
+						BearerToken:        "token",
+						InsecureSkipVerify: true,
+						Headers:            map[string]string{"X-Scope-OrgID": "tenant"},
+					},
+					Kubelet: KubeletConfig{Access: KubeletAccessDirect, Port: 10255, Workers: 4},
+				}
+				if !reflect.DeepEqual(c.DataSource, want) {
+					t.Errorf("DataSource = %+v, 期望 %+v", c.DataSource, want)
+				}
+				if len(c.PrometheusUrls) != 2 || c.Prometheus.Mode != PrometheusModeMerge {
+					t.Errorf("PrometheusUrls = %v, Mode = %v", c.PrometheusUrls, c.Prometheus.Mode)
+				}
+				if c.NodeName.Label != "node" {
+					t.Errorf("NodeName.Label = %v, 期望 node", c.NodeName.Label)
+				}
+				if m := c.Metrics[indexMetric(c.Metrics, MemoryMetricName)]; m.Threshold != 70 {
+					t.Errorf("memory 阈值 %v, 期望 70", m.Threshold)
+				}
+				if m := c.Metrics[indexMetric(c.Metrics, CPUMetricName)]; m.Threshold != 80 || m.RecoverThreshold != 60 {
+					t.Errorf("cpu 阈值 %v/%v, 期望 80/60", m.Threshold, m.RecoverThreshold)
+				}
+				if c.Staleness.Policy != StalePolicyReject || c.Intervals.Refresh != 30*time.Second {
+					t.Errorf("Staleness = %+v, Intervals = %+v", c.Staleness, c.Intervals)
+				}
+				if c.Reservation != (ReservationConfig{TTL: 2 * time.Minute, SampleLag: 10 * time.Second}) {
+					t.Errorf("Reservation = %+v", c.Reservation)
+				}
+				if !c.PodPolicy.BypassNamespaces["kube-system"] {
+					t.Errorf("BypassNamespaces = %v", c.PodPolicy.BypassNamespaces)
+				}
+			},
+		},
+		{
+			name: "配置文件中的自定义指标和算法",
+			file: `
+version: v1
+metrics:
+- name: load
+  query: node_load1
+  threshold: 8
+plugins:
+  predicates:
+  - name: CheckLoadLoad
+`,
+			check: func(t *testing.T, c *Config) {
+				if i := indexMetric(c.Metrics, "load"); i < 0 || c.Metrics[i].Plugin != "CheckLoadLoad" {
+					t.Errorf("Metrics = %+v", c.Metrics)
+				}
+				if len(c.Plugins.Predicates) != 1 || c.Plugins.Predicates[0].Name != "CheckLoadLoad" {
+					t.Errorf("Plugins = %+v", c.Plugins)
+				}
+			},
+		},
+		{name: "版本不支持", file: "version: v2\n", wantErr: "不支持"},
+		{name: "未知字段", file: "version: v1\nunknown: 1\n", wantErr: "解析"},
+		{name: "数据源类型不合法", file: "version: v1\ndataSource:\n  type: influxdb\n", wantErr: "data_source"},
+		{name: "数据源超时为 0", file: "version: v1\ndataSource:\n  timeout: 0s\n", wantErr: "data_source_timeout"},
+		{name: "kubelet 访问方式不合法", file: "version: v1\ndataSource:\n  kubelet:\n    access: ssh\n", wantErr: "kubelet_access"},
+		{name: "kubelet 端口不合法", file: "version: v1\ndataSource:\n  kubelet:\n    port: 70000\n", wantErr: "kubelet_port"},
+		{name: "kubelet workers 小于 1", file: "version: v1\ndataSource:\n  kubelet:\n    workers: 0\n", wantErr: "kubelet_workers"},
+		{name: "prometheus 模式不合法", file: "version: v1\ndataSource:\n  prometheus:\n    mode: random\n", wantErr: "prometheus_mode"},
+		{name: "过期策略不合法", file: "version: v1\nstaleness:\n  policy: drop\n", wantErr: "stale_policy"},
+		{name: "过期时间小于查询周期", file: "version: v1\nintervals:\n  overdue: 30s\n", wantErr: "node_overdue_time"},
+		{name: "预留样本延迟小于 0", file: "version: v1\nreservation:\n  sampleLag: -1s\n", wantErr: "reservation_sample_lag"},
+		{name: "低水位超过阈值", file: "version: v1\nthresholds:\n  memory: 70\n  memoryRecover: 75\n", wantErr: "recoverThreshold"},
+		{name: "算法配置不合法", file: "version: v1\nplugins:\n  priorities:\n  - weight: 1\n", wantErr: "算法名不能为空"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := testOptions()
+			if tt.file != "" {
+				o.ConfigFile = writeFile(t, t.TempDir(), tt.file)
+			}
+
+			c, err := Build(o)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Build() error = %v, 期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, c)
+		})
+	}
+}
+
+func TestDataSourceChanged(t *testing.T) {
+	base := DataSourceConfig{
+		Type:       PrometheusDataSource,
+		Timeout:    30 * time.Second,
+		Prometheus: PrometheusClientConfig{BasicAuthUsername: "admin", BasicAuthPassword: "secret"},
+		Kubelet:    KubeletConfig{Access: KubeletAccessProxy, Port: 10250, Workers: 16},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(d *DataSourceConfig)
+		want   []string
+	}{
+		{name: "没有修改", modify: func(d *DataSourceConfig) {}},
+		{name: "空 headers 和 nil 相同", modify: func(d *DataSourceConfig) { d.Prometheus.Headers = map[string]string{} }},
+		{name: "类型", modify: func(d *DataSourceConfig) { d.Type = MetricsServerDataSource }, want: []string{"type"}},
+		{name: "超时", modify: func(d *DataSourceConfig) { d.Timeout = time.Second }, want: []string{"timeout"}},
+		{name: "basic auth 密码", modify: func(d *DataSourceConfig) { d.Prometheus.BasicAuthPassword = "changed" }, want: []string{"prometheus.basicAuth"}},
+		{name: "TLS 和 headers", modify: func(d *DataSourceConfig) {
+			d.Prometheus.CAFile = "/etc/ca.pem"
+			d.Prometheus.Headers = map[string]string{"X-Scope-OrgID": "tenant"}
+		}, want: []string{"prometheus.tls", "prometheus.headers"}},
+		{name: "kubelet workers", modify: func(d *DataSourceConfig) { d.Kubelet.Workers = 4 }, want: []string{"kubelet"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := base
+			tt.modify(&d)
+			if got := d.Changed(base); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Changed() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,20 +1,70 @@
 package conf
 
-var Conf *config
+import (
+	"sync"
+	"sync/atomic"
+)
 
-type config struct {
-	PrometheusUrl             string
-	PrometheusMemoryMetrics   string
-	PrometheusMemoryThreshold int
-	LogRequestBody            bool
+// current 当前生效的配置,热加载时整体替换
+var current atomic.Value
+
+// Lock 预选、优选、抢占在处理请求期间持有读锁,热加载持有写锁替换配置和算法,
+// 保证一次请求内读到的配置是一致的
+var Lock sync.RWMutex
+
+// Get 返回当前生效的配置,返回的配置只读
+func Get() *Config {
+	c, _ := current.Load().(*Config)
+	return c
 }
 
-func NewConfig(PrometheusUrl, PrometheusMemoryMetrics string, PrometheusMemoryThreshold int, LogRequestBody bool) {
-	Conf = &config{
-		PrometheusUrl:             PrometheusUrl,
-		PrometheusMemoryMetrics:   PrometheusMemoryMetrics,
-		PrometheusMemoryThreshold: PrometheusMemoryThreshold,
-		LogRequestBody:            LogRequestBody,
-	}
+// Store 替换当前生效的配置,处理请求期间需要一致的调用方应持有 Lock 的写锁
+func Store(c *Config) {
+	current.Store(c)
+}
+
+type Config struct {
+	// PrometheusUrls 一个或多个 prometheus endpoint,多个时按 Prometheus.Mode 查询
+	PrometheusUrls []string
+	LogRequestBody bool
+
+	// DataSource 数据源及其连接参数,修改需要重启
+	DataSource DataSourceConfig
+
+	Prometheus PrometheusConfig
+
+	// Metrics 内置的 memory、cpu 指标,以及配置文件中定义的自定义指标
+	Metrics []MetricConfig
+
+	NodeName NodeNameConfig
+
+	Staleness StalenessConfig
 
+	Reservation ReservationConfig
+
+	MemoryFit MemoryFitConfig
+
+	// NodeThresholdAnnotations 是否读取 node annotation 中的阈值
+	NodeThresholdAnnotations bool
+
+	PodPolicy PodPolicyConfig
+
+	Plugins PluginsConfig
+
+	Intervals IntervalsConfig
+}
+
+func newConfig(PrometheusUrl, PrometheusMemoryMetrics string, PrometheusMemoryThreshold int, PrometheusCPUMetrics string, PrometheusCPUThreshold int, LogRequestBody bool) *Config {
+	metrics := builtinMetrics(PrometheusMemoryMetrics, PrometheusMemoryThreshold, PrometheusCPUMetrics, PrometheusCPUThreshold)
+	// 内置指标的配置一定合法
+	setupMetrics(metrics)
+
+	return &Config{
+		PrometheusUrls: splitUrls(PrometheusUrl),
+		LogRequestBody: LogRequestBody,
+		Metrics:        metrics,
+		NodeName:       NodeNameConfig{Label: defaultNodeLabel},
+		Prometheus:     PrometheusConfig{Mode: PrometheusModeFailover, MergeStrategy: MergeStrategyWorst},
+		Staleness:      StalenessConfig{Policy: StalePolicyAllow},
+	}
 }
@@ -0,0 +1,108 @@
+package conf
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const (
+	// PrometheusDataSource 从 prometheus 查询 PromQL
+	PrometheusDataSource = "prometheus"
+	// MetricsServerDataSource 从 metrics.k8s.io (metrics-server) 查询 NodeMetrics
+	MetricsServerDataSource = "metrics-server"
+	// KubeletDataSource 从每个 node 的 kubelet /stats/summary 查询
+	KubeletDataSource = "kubelet"
+
+	// KubeletAccessProxy 通过 API server 的 node proxy 访问 kubelet
+	KubeletAccessProxy = "proxy"
+	// KubeletAccessDirect 直接访问 node InternalIP 上的 kubelet 端口
+	KubeletAccessDirect = "direct"
+)
+
+// DataSourceConfig 数据源及其连接参数,只在启动时生效,热加载时修改需要重启
+type DataSourceConfig struct {
+	// Type prometheus、metrics-server 或 kubelet
+	Type string
+	// Timeout 每次查询数据源的超时时间
+	Timeout time.Duration
+
+	Prometheus PrometheusClientConfig
+	Kubelet    KubeletConfig
+}
+
+// PrometheusClientConfig 访问 prometheus 的认证、TLS 和 header
+type PrometheusClientConfig struct {
+	BearerToken       string
+	BearerTokenFile   string
+	BasicAuthUsername string
+	BasicAuthPassword string
+
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+
+	Headers map[string]string
+}
+
+// KubeletConfig 访问 kubelet summary api 的方式
+type KubeletConfig struct {
+	// Access proxy 或 direct
+	Access string
+	// Port direct 模式下 kubelet 端口
+	Port int
+	// InsecureSkipTLSVerify direct 模式下不校验 kubelet 证书
+	InsecureSkipTLSVerify bool
+	// Workers 并发采集的 node 数
+	Workers int
+}
+
+// setDataSource 设置数据源配置
+func (c *Config) setDataSource(d DataSourceConfig) error {
+	switch d.Type {
+	case PrometheusDataSource, MetricsServerDataSource, KubeletDataSource:
+	default:
+		return fmt.Errorf("data_source 只支持 %v/%v/%v", PrometheusDataSource, MetricsServerDataSource, KubeletDataSource)
+	}
+	if d.Timeout <= 0 {
+		return fmt.Errorf("data_source_timeout 必须大于 0")
+	}
+	if d.Kubelet.Access != KubeletAccessProxy && d.Kubelet.Access != KubeletAccessDirect {
+		return fmt.Errorf("kubelet_access 只支持 %v/%v", KubeletAccessProxy, KubeletAccessDirect)
+	}
+	if d.Kubelet.Port <= 0 || d.Kubelet.Port > 65535 {
+		return fmt.Errorf("kubelet_port %v 不合法", d.Kubelet.Port)
+	}
+	if d.Kubelet.Workers < 1 {
+		return fmt.Errorf("kubelet_workers 不能小于 1, 当前为 %v", d.Kubelet.Workers)
+	}
+
+	c.DataSource = d
+	return nil
+}
+
+// Changed 返回与 o 不同的配置项,用于热加载时拒绝需要重启的修改
+func (d DataSourceConfig) Changed(o DataSourceConfig) []string {
+	var changed []string
+	check := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			changed = append(changed, name)
+		}
+	}
+
+	check("type", d.Type, o.Type)
+	check("timeout", d.Timeout, o.Timeout)
+	check("prometheus.bearerToken", d.Prometheus.BearerToken, o.Prometheus.BearerToken)
+	check("prometheus.bearerTokenFile", d.Prometheus.BearerTokenFile, o.Prometheus.BearerTokenFile)
+	check("prometheus.basicAuth", [2]string{d.Prometheus.BasicAuthUsername, d.Prometheus.BasicAuthPassword},
+		[2]string{o.Prometheus.BasicAuthUsername, o.Prometheus.BasicAuthPassword})
+	check("prometheus.tls", [3]string{d.Prometheus.CAFile, d.Prometheus.CertFile, d.Prometheus.KeyFile},
+		[3]string{o.Prometheus.CAFile, o.Prometheus.CertFile, o.Prometheus.KeyFile})
+	check("prometheus.tls.insecureSkipVerify", d.Prometheus.InsecureSkipVerify, o.Prometheus.InsecureSkipVerify)
+	if len(d.Prometheus.Headers) != 0 || len(o.Prometheus.Headers) != 0 {
+		check("prometheus.headers", d.Prometheus.Headers, o.Prometheus.Headers)
+	}
+	check("kubelet", d.Kubelet, o.Kubelet)
+	return changed
+}
@@ -0,0 +1,249 @@
+package conf
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FileVersion 当前支持的配置文件版本
+const FileVersion = "v1"
+
+// File 结构化配置文件,yaml 或 json 格式. 没有配置的字段使用启动参数
+type File struct {
+	// Version 配置文件版本,必须为 v1
+	Version string `yaml:"version"`
+
+	// DataSource 数据源, type、timeout、prometheus 的认证/TLS/headers 和 kubelet 只在启动时生效,
+	// 热加载时修改会被拒绝
+	DataSource struct {
+		Type    *string        `yaml:"type"`
+		Timeout *time.Duration `yaml:"timeout"`
+
+		Prometheus struct {
+			Urls          []string `yaml:"urls"`
+			Mode          *string  `yaml:"mode"`
+			MergeStrategy *string  `yaml:"mergeStrategy"`
+
+			BearerToken     *string `yaml:"bearerToken"`
+			BearerTokenFile *string `yaml:"bearerTokenFile"`
+			BasicAuth       struct {
+				Username *string `yaml:"username"`
+				Password *string `yaml:"password"`
+			} `yaml:"basicAuth"`
+			TLS struct {
+				CAFile             *string `yaml:"caFile"`
+				CertFile           *string `yaml:"certFile"`
+				KeyFile            *string `yaml:"keyFile"`
+				InsecureSkipVerify *bool   `yaml:"insecureSkipVerify"`
+			} `yaml:"tls"`
+			Headers map[string]string `yaml:"headers"`
+		} `yaml:"prometheus"`
+
+		Kubelet struct {
+			Access                *string `yaml:"access"`
+			Port                  *int    `yaml:"port"`
+			InsecureSkipTLSVerify *bool   `yaml:"insecureSkipTLSVerify"`
+			Workers               *int    `yaml:"workers"`
+		} `yaml:"kubelet"`
+
+		NodeName struct {
+			Label       *string `yaml:"label"`
+			Regex       *string `yaml:"regex"`
+			Replacement *string `yaml:"replacement"`
+			Lookup      *bool   `yaml:"lookup"`
+		} `yaml:"nodeName"`
+	} `yaml:"dataSource"`
+
+	// Thresholds 内置 memory、cpu 指标的阈值
+	Thresholds struct {
+		Memory           *int  `yaml:"memory"`
+		MemoryRecover    *int  `yaml:"memoryRecover"`
+		MemoryGuaranteed *int  `yaml:"memoryGuaranteed"`
+		MemoryBurstable  *int  `yaml:"memoryBurstable"`
+		MemoryBestEffort *int  `yaml:"memoryBestEffort"`
+		CPU              *int  `yaml:"cpu"`
+		CPURecover       *int  `yaml:"cpuRecover"`
+		NodeAnnotations  *bool `yaml:"nodeAnnotations"`
+	} `yaml:"thresholds"`
+
+	// Metrics 自定义指标,与 --metrics_config_file 的格式相同,同名时覆盖内置指标
+	Metrics []MetricConfig `yaml:"metrics"`
+
+	// Plugins 预选、优选算法的顺序和权重,与 --plugins_config_file 的格式相同
+	Plugins *PluginsConfig `yaml:"plugins"`
+
+	Staleness struct {
+		Policy      *string        `yaml:"policy"`
+		GracePeriod *time.Duration `yaml:"gracePeriod"`
+	} `yaml:"staleness"`
+
+	Reservation struct {
+		TTL       *time.Duration `yaml:"ttl"`
+		SampleLag *time.Duration `yaml:"sampleLag"`
+	} `yaml:"reservation"`
+
+	MemoryFit struct {
+		Enabled       *bool   `yaml:"enabled"`
+		Basis         *string `yaml:"basis"`
+		CapacityQuery *string `yaml:"capacityQuery"`
+	} `yaml:"memoryFit"`
+
+	Bypass struct {
+		Namespaces      []string `yaml:"namespaces"`
+		PriorityClasses []string `yaml:"priorityClasses"`
+	} `yaml:"bypass"`
+
+	// Intervals 查询周期、过期时间和退避熔断参数
+	Intervals struct {
+		Refresh          *time.Duration `yaml:"refresh"`
+		Flush            *time.Duration `yaml:"flush"`
+		Overdue          *time.Duration `yaml:"overdue"`
+		Jitter           *float64       `yaml:"jitter"`
+		BackoffMax       *time.Duration `yaml:"backoffMax"`
+		BreakerThreshold *int           `yaml:"breakerThreshold"`
+		BreakerCooldown  *time.Duration `yaml:"breakerCooldown"`
+	} `yaml:"intervals"`
+}
+
+func loadFile(path string) (*File, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("解析 %v 出错: %v", path, err)
+	}
+	if f.Version != FileVersion {
+		return nil, fmt.Errorf("%v version %q 不支持,当前版本为 %v", path, f.Version, FileVersion)
+	}
+	return &f, nil
+}
+
+// override 用配置文件中配置了的字段覆盖启动参数
+func (f *File) override(o *Options) {
+	setString(&o.DataSource, f.DataSource.Type)
+	setDuration(&o.DataSourceTimeout, f.DataSource.Timeout)
+
+	prometheus := f.DataSource.Prometheus
+	if len(prometheus.Urls) != 0 {
+		o.PrometheusUrl = strings.Join(prometheus.Urls, ",")
+	}
+	setString(&o.PrometheusMode, prometheus.Mode)
+	setString(&o.PrometheusMergeStrategy, prometheus.MergeStrategy)
+	setString(&o.PrometheusBearerToken, prometheus.BearerToken)
+	setString(&o.PrometheusBearerTokenFile, prometheus.BearerTokenFile)
+	setString(&o.PrometheusBasicAuthUsername, prometheus.BasicAuth.Username)
+	setString(&o.PrometheusBasicAuthPassword, prometheus.BasicAuth.Password)
+	setString(&o.PrometheusCAFile, prometheus.TLS.CAFile)
+	setString(&o.PrometheusCertFile, prometheus.TLS.CertFile)
+	setString(&o.PrometheusKeyFile, prometheus.TLS.KeyFile)
+	setBool(&o.PrometheusInsecureSkipVerify, prometheus.TLS.InsecureSkipVerify)
+	if prometheus.Headers != nil {
+		o.PrometheusHeaders = prometheus.Headers
+	}
+
+	setString(&o.KubeletAccess, f.DataSource.Kubelet.Access)
+	setInt(&o.KubeletPort, f.DataSource.Kubelet.Port)
+	setBool(&o.KubeletInsecureTLS, f.DataSource.Kubelet.InsecureSkipTLSVerify)
+	setInt(&o.KubeletWorkers, f.DataSource.Kubelet.Workers)
+
+	setString(&o.NodeLabel, f.DataSource.NodeName.Label)
+	setString(&o.NodeNameRegex, f.DataSource.NodeName.Regex)
+	setString(&o.NodeNameReplacement, f.DataSource.NodeName.Replacement)
+	setBool(&o.NodeLookup, f.DataSource.NodeName.Lookup)
+
+	setInt(&o.MemoryThreshold, f.Thresholds.Memory)
+	setInt(&o.MemoryRecoverThreshold, f.Thresholds.MemoryRecover)
+	setInt(&o.MemoryGuaranteedThreshold, f.Thresholds.MemoryGuaranteed)
+	setInt(&o.MemoryBurstableThreshold, f.Thresholds.MemoryBurstable)
+	setInt(&o.MemoryBestEffortThreshold, f.Thresholds.MemoryBestEffort)
+	setInt(&o.CPUThreshold, f.Thresholds.CPU)
+	setInt(&o.CPURecoverThreshold, f.Thresholds.CPURecover)
+	setBool(&o.NodeThresholdAnnotations, f.Thresholds.NodeAnnotations)
+
+	setString(&o.StalePolicy, f.Staleness.Policy)
+	setDuration(&o.StaleGracePeriod, f.Staleness.GracePeriod)
+	setDuration(&o.ReservationTTL, f.Reservation.TTL)
+	setDuration(&o.ReservationSampleLag, f.Reservation.SampleLag)
+	setBool(&o.MemoryFit, f.MemoryFit.Enabled)
+	setString(&o.MemoryFitBasis, f.MemoryFit.Basis)
+	setString(&o.MemoryCapacityMetrics, f.MemoryFit.CapacityQuery)
+
+	if f.Bypass.Namespaces != nil {
+		o.BypassNamespaces = strings.Join(f.Bypass.Namespaces, ",")
+	}
+	if f.Bypass.PriorityClasses != nil {
+		o.BypassPriorityClasses = strings.Join(f.Bypass.PriorityClasses, ",")
+	}
+
+	setDuration(&o.RefreshInterval, f.Intervals.Refresh)
+	setDuration(&o.FlushInterval, f.Intervals.Flush)
+	setDuration(&o.OverdueTime, f.Intervals.Overdue)
+	setFloat(&o.IntervalJitter, f.Intervals.Jitter)
+	setDuration(&o.BackoffMax, f.Intervals.BackoffMax)
+	setInt(&o.BreakerThreshold, f.Intervals.BreakerThreshold)
+	setDuration(&o.BreakerCooldown, f.Intervals.BreakerCooldown)
+}
+
+func setString(dst *string, v *string) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func setInt(dst *int, v *int) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func setBool(dst *bool, v *bool) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func setFloat(dst *float64, v *float64) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func setDuration(dst *time.Duration, v *time.Duration) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+// Files 返回配置使用的所有文件
+func (o Options) Files() []string {
+	var files []string
+	for _, f := range []string{o.MetricsConfigFile, o.PluginsConfigFile, o.ConfigFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Checksum 计算所有配置文件内容的摘要,用于判断文件是否变化.
+// 比较内容而不是修改时间,兼容 ConfigMap 通过替换符号链接更新文件
+func (o Options) Checksum() (string, error) {
+	h := sha256.New()
+	for _, path := range o.Files() {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%v\x00%d\x00", path, len(b))
+		h.Write(b)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
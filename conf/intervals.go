@@ -0,0 +1,64 @@
+package conf
+
+import (
+	"fmt"
+	"time"
+)
+
+// IntervalsConfig 数据源查询、缓存清理的周期,以及查询连续失败时的退避和熔断
+type IntervalsConfig struct {
+	// Refresh 每个指标的查询周期
+	Refresh time.Duration
+	// Flush 清理过期缓存、更新降级状态的周期
+	Flush time.Duration
+	// Overdue 节点数据超过该时间没有更新即为过期
+	Overdue time.Duration
+	// Jitter 周期随机增加 0 ~ Jitter 倍,避免多个副本、多个指标同时查询
+	Jitter float64
+
+	// BackoffMax 连续失败时查询周期从 Refresh 开始翻倍,最长为 BackoffMax
+	BackoffMax time.Duration
+	// BreakerThreshold 连续失败该次数后熔断,BreakerCooldown 内不再查询,为 0 时不熔断
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// setIntervals 设置查询周期、过期时间和退避熔断参数
+func (c *Config) setIntervals(i IntervalsConfig) error {
+	if i.Refresh <= 0 || i.Flush <= 0 {
+		return fmt.Errorf("refresh_interval、flush_interval 必须大于 0")
+	}
+	if i.Overdue < i.Refresh {
+		return fmt.Errorf("node_overdue_time %v 不能小于 refresh_interval %v, 否则数据总是过期", i.Overdue, i.Refresh)
+	}
+	if i.Jitter < 0 || i.Jitter > 1 {
+		return fmt.Errorf("interval_jitter 必须在 0 ~ 1 之间")
+	}
+	if i.BackoffMax < i.Refresh {
+		return fmt.Errorf("backoff_max %v 不能小于 refresh_interval %v", i.BackoffMax, i.Refresh)
+	}
+	if i.BreakerThreshold < 0 {
+		return fmt.Errorf("breaker_threshold 不能小于 0")
+	}
+	if i.BreakerThreshold > 0 && i.BreakerCooldown <= 0 {
+		return fmt.Errorf("breaker_cooldown 必须大于 0")
+	}
+
+	c.Intervals = i
+	return nil
+}
+
+// Backoff 返回连续失败 failures 次后距离下次查询的时间,以及是否熔断
+func (i IntervalsConfig) Backoff(failures int) (time.Duration, bool) {
+	if i.BreakerThreshold > 0 && failures >= i.BreakerThreshold {
+		return i.BreakerCooldown, true
+	}
+	d := i.Refresh
+	for n := 1; n < failures && d < i.BackoffMax; n++ {
+		d *= 2
+	}
+	if d > i.BackoffMax {
+		d = i.BackoffMax
+	}
+	return d, false
+}
@@ -0,0 +1,85 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntervalsBackoff(t *testing.T) {
+	i := IntervalsConfig{
+		Refresh:          time.Minute,
+		BackoffMax:       5 * time.Minute,
+		BreakerThreshold: 5,
+		BreakerCooldown:  10 * time.Minute,
+	}
+
+	tests := []struct {
+		name      string
+		intervals IntervalsConfig
+		failures  int
+		want      time.Duration
+		wantOpen  bool
+	}{
+		{name: "第一次失败按正常周期重试", intervals: i, failures: 1, want: time.Minute},
+		{name: "连续失败周期翻倍", intervals: i, failures: 2, want: 2 * time.Minute},
+		{name: "继续翻倍", intervals: i, failures: 3, want: 4 * time.Minute},
+		{name: "不超过 BackoffMax", intervals: i, failures: 4, want: 5 * time.Minute},
+		{name: "达到阈值熔断", intervals: i, failures: 5, want: 10 * time.Minute, wantOpen: true},
+		{name: "熔断后再次失败仍然熔断", intervals: i, failures: 6, want: 10 * time.Minute, wantOpen: true},
+		{name: "阈值为 0 不熔断", intervals: IntervalsConfig{Refresh: time.Minute, BackoffMax: 5 * time.Minute}, failures: 100, want: 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, open := tt.intervals.Backoff(tt.failures)
+			if got != tt.want || open != tt.wantOpen {
+				t.Errorf("Backoff(%v) = %v, %v, 期望 %v, %v", tt.failures, got, open, tt.want, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestSetIntervals(t *testing.T) {
+	valid := IntervalsConfig{
+		Refresh:          time.Minute,
+		Flush:            30 * time.Second,
+		Overdue:          3 * time.Minute,
+		Jitter:           0.1,
+		BackoffMax:       5 * time.Minute,
+		BreakerThreshold: 5,
+		BreakerCooldown:  5 * time.Minute,
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(i *IntervalsConfig)
+		wantErr string
+	}{
+		{name: "合法", modify: func(i *IntervalsConfig) {}},
+		{name: "关闭熔断时不需要 cooldown", modify: func(i *IntervalsConfig) { i.BreakerThreshold, i.BreakerCooldown = 0, 0 }},
+		{name: "refresh 为 0", modify: func(i *IntervalsConfig) { i.Refresh = 0 }, wantErr: "refresh_interval"},
+		{name: "overdue 小于 refresh", modify: func(i *IntervalsConfig) { i.Overdue = 30 * time.Second }, wantErr: "node_overdue_time"},
+		{name: "jitter 超过 1", modify: func(i *IntervalsConfig) { i.Jitter = 1.5 }, wantErr: "interval_jitter"},
+		{name: "backoff_max 小于 refresh", modify: func(i *IntervalsConfig) { i.BackoffMax = 30 * time.Second }, wantErr: "backoff_max"},
+		{name: "breaker_threshold 小于 0", modify: func(i *IntervalsConfig) { i.BreakerThreshold = -1 }, wantErr: "breaker_threshold"},
+		{name: "开启熔断时 cooldown 为 0", modify: func(i *IntervalsConfig) { i.BreakerCooldown = 0 }, wantErr: "breaker_cooldown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := valid
+			tt.modify(&i)
+			err := (&Config{}).setIntervals(i)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("setIntervals() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("setIntervals() error = %v, 期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
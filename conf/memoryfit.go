@@ -0,0 +1,43 @@
+package conf
+
+import (
+	"fmt"
+)
+
+const (
+	// MemoryFitRequests 按 pod 的内存 request 判断能否放下
+	MemoryFitRequests = "requests"
+	// MemoryFitLimits 按 pod 的内存 limit 判断能否放下,没有设置 limit 的容器使用 request
+	MemoryFitLimits = "limits"
+)
+
+// MemoryFitConfig 按节点剩余内存(byte)和 pod 的内存大小过滤节点
+type MemoryFitConfig struct {
+	Enabled bool
+	Basis   string
+}
+
+// setMemoryFit 设置内存 fit 配置,capacityQuery 为内置 memory 指标查询节点内存容量的 PromQL,
+// 配置文件中已经配置 capacityQuery 时不覆盖
+func (c *Config) setMemoryFit(enabled bool, basis, capacityQuery string) error {
+	switch basis {
+	case MemoryFitRequests, MemoryFitLimits:
+	default:
+		return fmt.Errorf("memory_fit_basis 只支持 %v/%v", MemoryFitRequests, MemoryFitLimits)
+	}
+
+	if i := indexMetric(c.Metrics, MemoryMetricName); i >= 0 && c.Metrics[i].CapacityQuery == "" {
+		c.Metrics[i].CapacityQuery = capacityQuery
+	}
+
+	c.MemoryFit = MemoryFitConfig{
+		Enabled: enabled,
+		Basis:   basis,
+	}
+	return nil
+}
+
+// CapacityRequired 指标是否需要同时查询节点容量
+func (c *Config) CapacityRequired(m MetricConfig) bool {
+	return c.MemoryFit.Enabled && m.Name == MemoryMetricName
+}
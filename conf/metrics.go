@@ -0,0 +1,425 @@
+package conf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// MemoryMetricName 内置内存指标名
+	MemoryMetricName = "memory"
+	// CPUMetricName 内置CPU指标名
+	CPUMetricName = "cpu"
+	// FilesystemMetricName 节点根文件系统使用率,kubelet 数据源提供
+	FilesystemMetricName = "filesystem"
+	// PIDMetricName 节点进程数使用率,kubelet 数据源提供
+	PIDMetricName = "pid"
+
+	// ComparisonAbove 指标值 >= 阈值时过滤节点,适用于使用率类指标
+	ComparisonAbove = "above"
+	// ComparisonBelow 指标值 <= 阈值时过滤节点,适用于剩余量类指标
+	ComparisonBelow = "below"
+
+	// ScoreLowerBetter 指标值越低得分越高
+	ScoreLowerBetter = "lower"
+	// ScoreHigherBetter 指标值越高得分越高
+	ScoreHigherBetter = "higher"
+
+	// AggregationAvg 等聚合方式用于 range 查询,把窗口内的样本聚合为一个值
+	AggregationAvg  = "avg"
+	AggregationMax  = "max"
+	AggregationEWMA = "ewma"
+	// AggregationP95 分位数聚合,支持 p50、p90、p99 等任意 pNN
+	AggregationP95 = "p95"
+
+	// ForecastLinear 对最近的样本做线性回归预测
+	ForecastLinear = "linear"
+	// ForecastHolt 使用 Holt 双指数平滑预测
+	ForecastHolt = "holt"
+
+	defaultNodeLabel = "instance"
+
+	defaultRangeStep = 30 * time.Second
+	defaultEWMAAlpha = 0.3
+
+	defaultForecastSamples = 10
+	defaultForecastHorizon = 5 * time.Minute
+	defaultHoltAlpha       = 0.5
+	defaultHoltBeta        = 0.3
+)
+
+// MetricConfig 描述一个从 prometheus 查询的节点指标,以及由它生成的预选和优选算法
+type MetricConfig struct {
+	// Name 指标名,在配置中唯一
+	Name string `yaml:"name"`
+	// Plugin 预选/优选算法名,默认为 Check<Name>Load
+	Plugin string `yaml:"plugin"`
+	// Query PromQL 表达式,结果需为 vector,只有 prometheus 数据源使用
+	Query string `yaml:"query"`
+	// CapacityQuery 查询节点容量的 PromQL,单位与指标 100% 对应的绝对值相同,例如内存 byte
+	CapacityQuery string `yaml:"capacityQuery"`
+	// NodeLabel 结果中作为节点名的 label,默认使用 --prometheus_node_label
+	NodeLabel string `yaml:"nodeLabel"`
+	// Threshold 过滤阈值,开启滞后时为高水位
+	Threshold float64 `yaml:"threshold"`
+	// RecoverThreshold 低水位,不为 0 时开启滞后: 节点超过 Threshold 后一直被过滤,
+	// 直到指标值回落到低于 RecoverThreshold(comparison 为 below 时为高于),避免在阈值附近反复切换
+	RecoverThreshold float64 `yaml:"recoverThreshold"`
+	// Comparison 过滤方向: above 或 below,默认 above
+	Comparison string `yaml:"comparison"`
+	// ScoreDirection 打分方向: lower 或 higher,默认 lower
+	ScoreDirection string `yaml:"scoreDirection"`
+	// ScoreMin, ScoreMax 打分时指标值的取值范围,默认 0 ~ 100
+	ScoreMin float64 `yaml:"scoreMin"`
+	ScoreMax float64 `yaml:"scoreMax"`
+	// Weight 优选权重,默认 1
+	Weight int64 `yaml:"weight"`
+	// QoSThresholds 按 pod QoS class(Guaranteed、Burstable、BestEffort)覆盖阈值,
+	// 让容易被 OOM kill 的 pod 更早避开高负载节点
+	QoSThresholds map[v1.PodQOSClass]float64 `yaml:"qosThresholds"`
+	// NodeThresholds 按 node label 覆盖阈值的规则,按顺序匹配第一条
+	NodeThresholds []NodeThresholdRule `yaml:"nodeThresholds"`
+	// Range 不为空时使用 query_range 查询窗口内的样本并聚合,按持续负载而不是瞬时值判断节点
+	Range *RangeConfig `yaml:"range"`
+	// Forecast 不为空时额外注册 <Plugin>Forecast 预选和优选算法,按预测值判断节点
+	Forecast *ForecastConfig `yaml:"forecast"`
+}
+
+// NodeThresholdRule label selector 匹配的 node 使用单独的阈值
+type NodeThresholdRule struct {
+	// Selector label selector,例如 node-role.kubernetes.io/db=true
+	Selector string `yaml:"selector"`
+	// Threshold, RecoverThreshold 为 0 时沿用指标的配置
+	Threshold        float64 `yaml:"threshold"`
+	RecoverThreshold float64 `yaml:"recoverThreshold"`
+
+	selector labels.Selector
+}
+
+// Matches 判断 node label 是否匹配规则
+func (r *NodeThresholdRule) Matches(nodeLabels map[string]string) bool {
+	return r.selector != nil && r.selector.Matches(labels.Set(nodeLabels))
+}
+
+// RangeConfig range 查询配置
+type RangeConfig struct {
+	// Window 查询窗口,例如 5m
+	Window time.Duration `yaml:"window"`
+	// Step 查询步长,默认 30s
+	Step time.Duration `yaml:"step"`
+	// Aggregation avg、max、ewma 或 pNN(例如 p95),默认 avg
+	Aggregation string `yaml:"aggregation"`
+	// Alpha ewma 的平滑系数,取值 (0, 1],越大越接近最新样本,默认 0.3
+	Alpha float64 `yaml:"alpha"`
+}
+
+// ForecastConfig 负载预测配置,根据每个节点最近 Samples 次查询结果预测 Horizon 之后的指标值
+type ForecastConfig struct {
+	// Method linear 或 holt,默认 linear
+	Method string `yaml:"method"`
+	// Samples 参与预测的历史样本数,默认 10
+	Samples int `yaml:"samples"`
+	// Horizon 预测多久之后的值,默认 5m
+	Horizon time.Duration `yaml:"horizon"`
+	// Alpha, Beta holt 的水平和趋势平滑系数,取值 (0, 1],默认 0.5、0.3
+	Alpha float64 `yaml:"alpha"`
+	Beta  float64 `yaml:"beta"`
+	// Weight 预测优选算法的权重,默认与指标的 weight 相同
+	Weight int64 `yaml:"weight"`
+}
+
+// ForecastPlugin 预测算法名
+func (m *MetricConfig) ForecastPlugin() string {
+	return m.Plugin + "Forecast"
+}
+
+// Percentile 解析 pNN 聚合方式,返回分位数
+func (r *RangeConfig) Percentile() (float64, bool) {
+	if !strings.HasPrefix(r.Aggregation, "p") {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(r.Aggregation[1:], 64)
+	if err != nil || p <= 0 || p > 100 {
+		return 0, false
+	}
+	return p, true
+}
+
+type metricsFile struct {
+	Metrics []MetricConfig `yaml:"metrics"`
+}
+
+// Exceeds 判断指标值是否超过过滤阈值
+func (m *MetricConfig) Exceeds(value float64) bool {
+	if m.Comparison == ComparisonBelow {
+		return value <= m.Threshold
+	}
+	return value >= m.Threshold
+}
+
+// Hysteresis 是否开启滞后
+func (m *MetricConfig) Hysteresis() bool {
+	return m.RecoverThreshold != 0
+}
+
+// Recovered 判断被过滤的节点指标值是否已经回到低水位以内
+func (m *MetricConfig) Recovered(value float64) bool {
+	if m.Comparison == ComparisonBelow {
+		return value > m.RecoverThreshold
+	}
+	return value < m.RecoverThreshold
+}
+
+// Stricter 判断 m 的阈值是否比 threshold 更严格: comparison 为 above 时更小, below 时更大
+func (m *MetricConfig) Stricter(threshold float64) bool {
+	if m.Comparison == ComparisonBelow {
+		return m.Threshold > threshold
+	}
+	return m.Threshold < threshold
+}
+
+func (m *MetricConfig) setDefaults() {
+	if m.Plugin == "" {
+		m.Plugin = "Check" + strings.Title(m.Name) + "Load"
+	}
+	if m.Comparison == "" {
+		m.Comparison = ComparisonAbove
+	}
+	if m.ScoreDirection == "" {
+		m.ScoreDirection = ScoreLowerBetter
+	}
+	if m.ScoreMin == 0 && m.ScoreMax == 0 {
+		m.ScoreMax = 100
+	}
+	if m.Weight <= 0 {
+		m.Weight = 1
+	}
+	if m.Range != nil {
+		if m.Range.Step <= 0 {
+			m.Range.Step = defaultRangeStep
+		}
+		if m.Range.Aggregation == "" {
+			m.Range.Aggregation = AggregationAvg
+		}
+		if m.Range.Alpha == 0 {
+			m.Range.Alpha = defaultEWMAAlpha
+		}
+	}
+	if f := m.Forecast; f != nil {
+		if f.Method == "" {
+			f.Method = ForecastLinear
+		}
+		if f.Samples == 0 {
+			f.Samples = defaultForecastSamples
+		}
+		if f.Horizon == 0 {
+			f.Horizon = defaultForecastHorizon
+		}
+		if f.Alpha == 0 {
+			f.Alpha = defaultHoltAlpha
+		}
+		if f.Beta == 0 {
+			f.Beta = defaultHoltBeta
+		}
+		if f.Weight <= 0 {
+			f.Weight = m.Weight
+		}
+	}
+}
+
+func (m *MetricConfig) validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("metric name 不能为空")
+	}
+	if m.Comparison != ComparisonAbove && m.Comparison != ComparisonBelow {
+		return fmt.Errorf("metric %v comparison 只支持 %v/%v", m.Name, ComparisonAbove, ComparisonBelow)
+	}
+	if m.ScoreDirection != ScoreLowerBetter && m.ScoreDirection != ScoreHigherBetter {
+		return fmt.Errorf("metric %v scoreDirection 只支持 %v/%v", m.Name, ScoreLowerBetter, ScoreHigherBetter)
+	}
+	if m.ScoreMax <= m.ScoreMin {
+		return fmt.Errorf("metric %v scoreMax 必须大于 scoreMin", m.Name)
+	}
+	if err := m.validateRecoverThreshold(); err != nil {
+		return err
+	}
+	if err := m.validateQoSThresholds(); err != nil {
+		return err
+	}
+	for i := range m.NodeThresholds {
+		rule := &m.NodeThresholds[i]
+		selector, err := labels.Parse(rule.Selector)
+		if err != nil || rule.Selector == "" {
+			return fmt.Errorf("metric %v nodeThresholds selector %q 格式错误: %v", m.Name, rule.Selector, err)
+		}
+		rule.selector = selector
+	}
+	if r := m.Range; r != nil {
+		if r.Window <= 0 || r.Window < r.Step {
+			return fmt.Errorf("metric %v range.window 必须大于 0 且不小于 range.step", m.Name)
+		}
+		switch r.Aggregation {
+		case AggregationAvg, AggregationMax, AggregationEWMA:
+		default:
+			if _, ok := r.Percentile(); !ok {
+				return fmt.Errorf("metric %v range.aggregation 只支持 %v/%v/%v/pNN", m.Name, AggregationAvg, AggregationMax, AggregationEWMA)
+			}
+		}
+		if r.Alpha <= 0 || r.Alpha > 1 {
+			return fmt.Errorf("metric %v range.alpha 取值范围为 (0, 1]", m.Name)
+		}
+	}
+	if f := m.Forecast; f != nil {
+		if f.Method != ForecastLinear && f.Method != ForecastHolt {
+			return fmt.Errorf("metric %v forecast.method 只支持 %v/%v", m.Name, ForecastLinear, ForecastHolt)
+		}
+		if f.Samples < 2 {
+			return fmt.Errorf("metric %v forecast.samples 不能小于 2", m.Name)
+		}
+		if f.Horizon < 0 {
+			return fmt.Errorf("metric %v forecast.horizon 不能小于 0", m.Name)
+		}
+		if f.Alpha <= 0 || f.Alpha > 1 || f.Beta <= 0 || f.Beta > 1 {
+			return fmt.Errorf("metric %v forecast.alpha/beta 取值范围为 (0, 1]", m.Name)
+		}
+	}
+	return nil
+}
+
+func (m *MetricConfig) validateRecoverThreshold() error {
+	if !m.Hysteresis() {
+		return nil
+	}
+	if m.Comparison == ComparisonBelow && m.RecoverThreshold < m.Threshold {
+		return fmt.Errorf("metric %v comparison 为 below 时 recoverThreshold 不能小于 threshold", m.Name)
+	}
+	if m.Comparison == ComparisonAbove && m.RecoverThreshold > m.Threshold {
+		return fmt.Errorf("metric %v recoverThreshold 不能大于 threshold", m.Name)
+	}
+	return nil
+}
+
+// setRecoverThresholds 设置内置 memory、cpu 指标的低水位,为 0 或者配置文件中已经配置时不覆盖
+func (c *Config) setRecoverThresholds(memory, cpu int) error {
+	for name, value := range map[string]int{MemoryMetricName: memory, CPUMetricName: cpu} {
+		i := indexMetric(c.Metrics, name)
+		if i < 0 || value == 0 || c.Metrics[i].Hysteresis() {
+			continue
+		}
+		c.Metrics[i].RecoverThreshold = float64(value)
+		if err := c.Metrics[i].validateRecoverThreshold(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func builtinMetrics(memoryQuery string, memoryThreshold int, cpuQuery string, cpuThreshold int) []MetricConfig {
+	return []MetricConfig{
+		{
+			Name:      MemoryMetricName,
+			Plugin:    "CheckMemoryLoad",
+			Query:     memoryQuery,
+			Threshold: float64(memoryThreshold),
+		},
+		{
+			Name:      CPUMetricName,
+			Plugin:    "CheckCPULoad",
+			Query:     cpuQuery,
+			Threshold: float64(cpuThreshold),
+		},
+	}
+}
+
+// loadMetricsConfig 从 yaml 文件读取自定义指标,追加到 c.Metrics
+func (c *Config) loadMetricsConfig(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var f metricsFile
+	if err := yaml.UnmarshalStrict(b, &f); err != nil {
+		return fmt.Errorf("解析 %v 出错: %v", path, err)
+	}
+	return c.mergeMetrics(f.Metrics)
+}
+
+// mergeMetrics 追加自定义指标到 c.Metrics
+func (c *Config) mergeMetrics(custom []MetricConfig) error {
+	metrics := append([]MetricConfig{}, c.Metrics...)
+	for _, m := range custom {
+		// 与内置指标同名时覆盖内置指标,未配置的 query、plugin、threshold 沿用内置值
+		if i := indexMetric(metrics, m.Name); i >= 0 && isBuiltinMetric(m.Name) {
+			if m.Query == "" {
+				m.Query = metrics[i].Query
+			}
+			if m.Plugin == "" {
+				m.Plugin = metrics[i].Plugin
+			}
+			if m.Threshold == 0 {
+				m.Threshold = metrics[i].Threshold
+			}
+			metrics[i] = m
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	if err := setupMetrics(metrics); err != nil {
+		return err
+	}
+
+	c.Metrics = metrics
+	return nil
+}
+
+func setupMetrics(metrics []MetricConfig) error {
+	names := make(map[string]bool, len(metrics))
+	plugins := make(map[string]bool, len(metrics))
+	for i := range metrics {
+		m := &metrics[i]
+		m.setDefaults()
+		if err := m.validate(); err != nil {
+			return err
+		}
+		if names[m.Name] {
+			return fmt.Errorf("metric %v 重复定义", m.Name)
+		}
+		if plugins[m.Plugin] {
+			return fmt.Errorf("plugin %v 重复定义", m.Plugin)
+		}
+		names[m.Name] = true
+		plugins[m.Plugin] = true
+		if m.Forecast != nil {
+			if plugins[m.ForecastPlugin()] {
+				return fmt.Errorf("plugin %v 重复定义", m.ForecastPlugin())
+			}
+			plugins[m.ForecastPlugin()] = true
+		}
+	}
+	return nil
+}
+
+func indexMetric(metrics []MetricConfig, name string) int {
+	for i := range metrics {
+		if metrics[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func isBuiltinMetric(name string) bool {
+	return name == MemoryMetricName || name == CPUMetricName
+}
@@ -0,0 +1,47 @@
+package conf
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// NodeNameConfig 描述如何把 prometheus 结果中的 label 值转换为 k8s node 名
+type NodeNameConfig struct {
+	// Label 默认作为节点名的 label,指标未配置 nodeLabel 时使用
+	Label string
+	// Regex 不为空时,匹配的 label 值会被替换为 Replacement,支持 $1 等分组引用
+	Regex       *regexp.Regexp
+	Replacement string
+	// Lookup 为 true 时通过 node informer 按 InternalIP/Hostname 查找真实的 node 名
+	Lookup bool
+}
+
+// setNodeNameMapping 设置节点名转换规则
+func (c *Config) setNodeNameMapping(label, regex, replacement string, lookup bool) error {
+	n := NodeNameConfig{
+		Label:       label,
+		Replacement: replacement,
+		Lookup:      lookup,
+	}
+	if n.Label == "" {
+		n.Label = defaultNodeLabel
+	}
+	if regex != "" {
+		r, err := regexp.Compile("^(?:" + regex + ")$")
+		if err != nil {
+			return fmt.Errorf("node_name_regex %v 不合法: %v", regex, err)
+		}
+		n.Regex = r
+	}
+
+	c.NodeName = n
+	return nil
+}
+
+// NodeLabel 返回指标使用的节点名 label
+func (c *Config) NodeLabel(m MetricConfig) string {
+	if m.NodeLabel != "" {
+		return m.NodeLabel
+	}
+	return c.NodeName.Label
+}
@@ -0,0 +1,50 @@
+package conf
+
+import "testing"
+
+func TestSetNodeNameMapping(t *testing.T) {
+	tests := []struct {
+		name      string
+		label     string
+		regex     string
+		wantLabel string
+		// match, noMatch 改写前的 label 值,正则需要完整匹配
+		match   string
+		noMatch string
+		wantErr bool
+	}{
+		{name: "默认 label", wantLabel: defaultNodeLabel},
+		{name: "指定 label", label: "node", wantLabel: "node"},
+		{name: "正则", label: "node", regex: `(.+):\d+`, wantLabel: "node", match: "node-1:9100", noMatch: "node-1"},
+		{name: "正则需要完整匹配", regex: `node`, wantLabel: defaultNodeLabel, match: "node", noMatch: "node-1"},
+		{name: "正则不合法", regex: `(`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{}
+			err := c.setNodeNameMapping(tt.label, tt.regex, "$1", false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setNodeNameMapping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if c.NodeName.Label != tt.wantLabel {
+				t.Errorf("Label = %v, 期望 %v", c.NodeName.Label, tt.wantLabel)
+			}
+			if got := c.NodeLabel(MetricConfig{Name: "load"}); got != tt.wantLabel {
+				t.Errorf("NodeLabel() = %v, 期望 %v", got, tt.wantLabel)
+			}
+			if got := c.NodeLabel(MetricConfig{Name: "load", NodeLabel: "host"}); got != "host" {
+				t.Errorf("指标配置了 nodeLabel 时 NodeLabel() = %v, 期望 host", got)
+			}
+			if tt.match != "" && !c.NodeName.Regex.MatchString(tt.match) {
+				t.Errorf("正则 %v 期望匹配 %v", c.NodeName.Regex, tt.match)
+			}
+			if tt.noMatch != "" && c.NodeName.Regex.MatchString(tt.noMatch) {
+				t.Errorf("正则 %v 期望不匹配 %v", c.NodeName.Regex, tt.noMatch)
+			}
+		})
+	}
+}
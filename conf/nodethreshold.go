@@ -0,0 +1,77 @@
+package conf
+
+import (
+	"strconv"
+)
+
+const (
+	// AnnotationPrefix extender 使用的 annotation 前缀
+	AnnotationPrefix = "kube-scheduler-extender/"
+)
+
+// ThresholdAnnotation node 上覆盖指标阈值的 annotation, 例如 kube-scheduler-extender/memory-threshold
+func ThresholdAnnotation(metric string) string {
+	return AnnotationPrefix + metric + "-threshold"
+}
+
+// RecoverThresholdAnnotation node 上覆盖指标低水位的 annotation
+func RecoverThresholdAnnotation(metric string) string {
+	return AnnotationPrefix + metric + "-recover-threshold"
+}
+
+// setNodeThresholdAnnotations 设置是否读取 node annotation 中的阈值
+func (c *Config) setNodeThresholdAnnotations(enabled bool) {
+	c.NodeThresholdAnnotations = enabled
+}
+
+// NodeThresholdsEnabled 是否需要按 node 计算阈值: 开启了 annotation 或者配置了 label 规则
+func (c *Config) NodeThresholdsEnabled() bool {
+	if c.NodeThresholdAnnotations {
+		return true
+	}
+	for _, m := range c.Metrics {
+		if len(m.NodeThresholds) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ForNode 返回按 node label 规则和 annotation 覆盖阈值后的指标配置, annotation 优先
+func (m MetricConfig) ForNode(nodeLabels, annotations map[string]string) (MetricConfig, error) {
+	for _, rule := range m.NodeThresholds {
+		if !rule.Matches(nodeLabels) {
+			continue
+		}
+		if rule.Threshold != 0 {
+			m.Threshold = rule.Threshold
+		}
+		if rule.RecoverThreshold != 0 {
+			m.RecoverThreshold = rule.RecoverThreshold
+		}
+		break
+	}
+
+	if Get().NodeThresholdAnnotations {
+		for key, target := range map[string]*float64{
+			ThresholdAnnotation(m.Name):        &m.Threshold,
+			RecoverThresholdAnnotation(m.Name): &m.RecoverThreshold,
+		} {
+			v, exist := annotations[key]
+			if !exist {
+				continue
+			}
+			value, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return m, err
+			}
+			*target = value
+		}
+	}
+
+	// 只覆盖了阈值时,低水位可能越过阈值,此时关闭滞后
+	if m.validateRecoverThreshold() != nil {
+		m.RecoverThreshold = 0
+	}
+	return m, nil
+}
@@ -0,0 +1,143 @@
+package conf
+
+import "testing"
+
+func TestNodeThresholdRuleValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		wantErr  bool
+	}{
+		{name: "等值", selector: "node-role.kubernetes.io/db=true"},
+		{name: "集合", selector: "zone in (a,b)"},
+		{name: "selector 为空", selector: "", wantErr: true},
+		{name: "selector 格式错误", selector: "zone in (a", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := []MetricConfig{{
+				Name:           "load",
+				Threshold:      80,
+				NodeThresholds: []NodeThresholdRule{{Selector: tt.selector, Threshold: 60}},
+			}}
+			err := setupMetrics(metrics)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setupMetrics() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestForNode(t *testing.T) {
+	old := Get()
+	defer func() {
+		if old != nil {
+			Store(old)
+		}
+	}()
+
+	metrics := []MetricConfig{{
+		Name:             "load",
+		Threshold:        80,
+		RecoverThreshold: 60,
+		NodeThresholds: []NodeThresholdRule{
+			{Selector: "role=db", Threshold: 50, RecoverThreshold: 40},
+			// 只覆盖阈值,低水位沿用指标配置
+			{Selector: "role=web", Threshold: 70},
+			// 前面的规则已经匹配时不再生效
+			{Selector: "zone=a", Threshold: 30, RecoverThreshold: 20},
+		},
+	}}
+	if err := setupMetrics(metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		annotations bool
+		labels      map[string]string
+		annotation  map[string]string
+		want        float64
+		wantRecover float64
+		wantErr     bool
+	}{
+		{name: "没有匹配的规则", labels: map[string]string{"role": "cache"}, want: 80, wantRecover: 60},
+		{name: "没有 label", want: 80, wantRecover: 60},
+		{name: "匹配规则", labels: map[string]string{"role": "db"}, want: 50, wantRecover: 40},
+		{name: "按顺序匹配第一条", labels: map[string]string{"role": "db", "zone": "a"}, want: 50, wantRecover: 40},
+		{name: "只覆盖阈值", labels: map[string]string{"role": "web"}, want: 70, wantRecover: 60},
+		{name: "第三条规则", labels: map[string]string{"zone": "a"}, want: 30, wantRecover: 20},
+		{
+			name:       "未开启 annotation 时忽略",
+			labels:     map[string]string{"role": "db"},
+			annotation: map[string]string{ThresholdAnnotation("load"): "90"},
+			want:       50, wantRecover: 40,
+		},
+		{
+			name:        "annotation 优先于规则",
+			annotations: true,
+			labels:      map[string]string{"role": "db"},
+			annotation:  map[string]string{ThresholdAnnotation("load"): "90", RecoverThresholdAnnotation("load"): "70"},
+			want:        90, wantRecover: 70,
+		},
+		{
+			name:        "annotation 阈值低于低水位时关闭滞后",
+			annotations: true,
+			annotation:  map[string]string{ThresholdAnnotation("load"): "55"},
+			want:        55, wantRecover: 0,
+		},
+		{
+			name:        "其它指标的 annotation 忽略",
+			annotations: true,
+			annotation:  map[string]string{ThresholdAnnotation("memory"): "10"},
+			want:        80, wantRecover: 60,
+		},
+		{
+			name:        "annotation 格式错误",
+			annotations: true,
+			annotation:  map[string]string{ThresholdAnnotation("load"): "90%"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Store(&Config{NodeThresholdAnnotations: tt.annotations})
+			got, err := metrics[0].ForNode(tt.labels, tt.annotation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ForNode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Threshold != tt.want || got.RecoverThreshold != tt.wantRecover {
+				t.Errorf("ForNode() 阈值 = %v/%v, 期望 %v/%v", got.Threshold, got.RecoverThreshold, tt.want, tt.wantRecover)
+			}
+		})
+	}
+}
+
+func TestNodeThresholdsEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		conf Config
+		want bool
+	}{
+		{name: "未配置", conf: Config{Metrics: []MetricConfig{{Name: "load"}}}},
+		{name: "开启 annotation", conf: Config{NodeThresholdAnnotations: true}, want: true},
+		{
+			name: "配置了 label 规则",
+			conf: Config{Metrics: []MetricConfig{{Name: "load"}, {Name: "cpu", NodeThresholds: []NodeThresholdRule{{Selector: "a=b"}}}}},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.conf.NodeThresholdsEnabled(); got != tt.want {
+				t.Errorf("NodeThresholdsEnabled() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package conf
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PluginsConfig 选择执行哪些预选、优选算法,以及执行顺序和优选权重. 为 nil 时使用所有注册的算法
+type PluginsConfig struct {
+	// Predicates 按顺序执行的预选算法,配置为空列表时不执行预选
+	Predicates []PluginConfig `yaml:"predicates"`
+	// Priorities 参与打分的优选算法,配置为空列表时所有节点得分相同
+	Priorities []PluginConfig `yaml:"priorities"`
+}
+
+// PluginConfig 一个算法
+type PluginConfig struct {
+	// Name 注册的算法名,例如 CheckMemoryLoad、CheckMemoryFit
+	Name string `yaml:"name"`
+	// Weight 优选权重,为 0 时使用注册时的默认权重(指标的 weight),预选忽略
+	Weight int64 `yaml:"weight"`
+	// Args 算法参数,由算法创建时解码为自己的参数类型
+	Args PluginArgs `yaml:"args"`
+}
+
+// PluginArgs 算法参数,yaml 或 json 对象
+type PluginArgs map[string]interface{}
+
+// UnmarshalYAML 把嵌套对象的 key 转换为 string,保证参数可以输出为 json
+func (a *PluginArgs) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var m map[string]interface{}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	for k, v := range m {
+		m[k] = stringKeys(v)
+	}
+	*a = m
+	return nil
+}
+
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+	}
+	return v
+}
+
+// Decode 把参数解码到 out,有未知字段或类型不匹配时返回错误,没有参数时不修改 out
+func (a PluginArgs) Decode(out interface{}) error {
+	if len(a) == 0 {
+		return nil
+	}
+	b, err := yaml.Marshal(map[string]interface{}(a))
+	if err != nil {
+		return err
+	}
+	return yaml.UnmarshalStrict(b, out)
+}
+
+func (c *PluginsConfig) validate() error {
+	for kind, plugins := range map[string][]PluginConfig{"predicates": c.Predicates, "priorities": c.Priorities} {
+		names := make(map[string]bool)
+		for _, p := range plugins {
+			if p.Name == "" {
+				return fmt.Errorf("%v 中的算法名不能为空", kind)
+			}
+			if names[p.Name] {
+				return fmt.Errorf("%v 中的算法 %v 重复", kind, p.Name)
+			}
+			if p.Weight < 0 {
+				return fmt.Errorf("%v 中的算法 %v weight 不能小于 0", kind, p.Name)
+			}
+			names[p.Name] = true
+		}
+	}
+	return nil
+}
+
+// loadPluginsConfig 从 yaml 文件读取算法配置,算法名是否已注册在 algorithm.NewPlugins 调用 Registry.Enable 时检查
+func (c *Config) loadPluginsConfig(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var p PluginsConfig
+	if err := yaml.UnmarshalStrict(b, &p); err != nil {
+		return fmt.Errorf("解析 %v 出错: %v", path, err)
+	}
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	c.Plugins = p
+	return nil
+}
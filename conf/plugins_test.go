@@ -0,0 +1,55 @@
+package conf
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestPluginArgsDecode(t *testing.T) {
+	type args struct {
+		Basis  string `yaml:"basis"`
+		Limits struct {
+			Memory string `yaml:"memory"`
+		} `yaml:"limits"`
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		want    args
+		wantErr bool
+	}{
+		{name: "没有参数时保留默认值", yaml: "name: CheckMemoryFit\n", want: args{Basis: "requests"}},
+		{name: "解码参数", yaml: "name: CheckMemoryFit\nargs:\n  basis: limits\n  limits:\n    memory: 1Gi\n", want: func() args {
+			a := args{Basis: "limits"}
+			a.Limits.Memory = "1Gi"
+			return a
+		}()},
+		{name: "未知参数", yaml: "name: CheckMemoryFit\nargs:\n  size: 1Gi\n", wantErr: true},
+		{name: "类型不匹配", yaml: "name: CheckMemoryFit\nargs:\n  limits: 1Gi\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p PluginConfig
+			if err := yaml.UnmarshalStrict([]byte(tt.yaml), &p); err != nil {
+				t.Fatal(err)
+			}
+			// 嵌套参数可以输出为 json, 用于 /admin/config
+			if _, err := json.Marshal(p.Args); err != nil {
+				t.Fatalf("参数不能输出为 json: %v", err)
+			}
+
+			got := args{Basis: "requests"}
+			err := p.Args.Decode(&got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("Decode() = %+v, 期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package conf
+
+import (
+	"strings"
+)
+
+const (
+	// SkipAnnotation pod annotation,值为 true 时跳过所有负载算法
+	SkipAnnotation = AnnotationPrefix + "skip"
+	// DisablePluginsAnnotation pod annotation,逗号分隔的算法名,这些预选和优选算法对该 pod 不生效
+	DisablePluginsAnnotation = AnnotationPrefix + "disable-plugins"
+)
+
+// PodPolicyConfig 总是跳过负载算法的 pod
+type PodPolicyConfig struct {
+	BypassNamespaces      map[string]bool
+	BypassPriorityClasses map[string]bool
+}
+
+// setPodBypass 设置总是跳过负载算法的 namespace 和 PriorityClass, 均为逗号分隔
+func (c *Config) setPodBypass(namespaces, priorityClasses string) {
+	c.PodPolicy = PodPolicyConfig{
+		BypassNamespaces:      splitSet(namespaces),
+		BypassPriorityClasses: splitSet(priorityClasses),
+	}
+}
+
+func splitSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,45 @@
+package conf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetPodBypass(t *testing.T) {
+	tests := []struct {
+		name                string
+		namespaces          string
+		priorityClasses     string
+		wantNamespaces      map[string]bool
+		wantPriorityClasses map[string]bool
+	}{
+		{name: "未配置", wantNamespaces: map[string]bool{}, wantPriorityClasses: map[string]bool{}},
+		{
+			name:                "逗号分隔并去掉空格",
+			namespaces:          "kube-system, monitoring",
+			priorityClasses:     "system-node-critical",
+			wantNamespaces:      map[string]bool{"kube-system": true, "monitoring": true},
+			wantPriorityClasses: map[string]bool{"system-node-critical": true},
+		},
+		{
+			name:                "忽略空值",
+			namespaces:          ",kube-system,,",
+			priorityClasses:     " , ",
+			wantNamespaces:      map[string]bool{"kube-system": true},
+			wantPriorityClasses: map[string]bool{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{}
+			c.setPodBypass(tt.namespaces, tt.priorityClasses)
+			if !reflect.DeepEqual(c.PodPolicy.BypassNamespaces, tt.wantNamespaces) {
+				t.Errorf("BypassNamespaces = %v, 期望 %v", c.PodPolicy.BypassNamespaces, tt.wantNamespaces)
+			}
+			if !reflect.DeepEqual(c.PodPolicy.BypassPriorityClasses, tt.wantPriorityClasses) {
+				t.Errorf("BypassPriorityClasses = %v, 期望 %v", c.PodPolicy.BypassPriorityClasses, tt.wantPriorityClasses)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// PrometheusModeFailover 按顺序查询 endpoint,第一个成功的结果生效
+	PrometheusModeFailover = "failover"
+	// PrometheusModeMerge 查询所有 endpoint,按 MergeStrategy 合并每个 node 的结果
+	PrometheusModeMerge = "merge"
+
+	// MergeStrategyWorst 每个 node 取负载最高的值(comparison 为 below 时取最小值)
+	MergeStrategyWorst = "worst"
+	// MergeStrategyFreshest 每个 node 取样本时间最新的值
+	MergeStrategyFreshest = "freshest"
+)
+
+// PrometheusConfig 多个 prometheus endpoint 的高可用配置
+type PrometheusConfig struct {
+	Mode          string
+	MergeStrategy string
+}
+
+// setPrometheusHA 设置多个 prometheus endpoint 的查询模式
+func (c *Config) setPrometheusHA(mode, mergeStrategy string) error {
+	if mode != PrometheusModeFailover && mode != PrometheusModeMerge {
+		return fmt.Errorf("prometheus_mode 只支持 %v/%v", PrometheusModeFailover, PrometheusModeMerge)
+	}
+	if mergeStrategy != MergeStrategyWorst && mergeStrategy != MergeStrategyFreshest {
+		return fmt.Errorf("prometheus_merge_strategy 只支持 %v/%v", MergeStrategyWorst, MergeStrategyFreshest)
+	}
+
+	c.Prometheus = PrometheusConfig{
+		Mode:          mode,
+		MergeStrategy: mergeStrategy,
+	}
+	return nil
+}
+
+// splitUrls 解析逗号分隔的 prometheus url
+func splitUrls(s string) []string {
+	var urls []string
+	for _, u := range strings.Split(s, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
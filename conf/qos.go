@@ -0,0 +1,75 @@
+package conf
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// ForQoS 返回按 pod QoS class 覆盖阈值后的指标配置,没有配置该 class 时返回 false.
+// 低水位仍在 QoS 阈值以内时保留滞后,否则关闭
+func (m MetricConfig) ForQoS(class v1.PodQOSClass) (MetricConfig, bool) {
+	threshold, exist := m.QoSThresholds[class]
+	if !exist {
+		return m, false
+	}
+	m.Threshold = threshold
+	if m.validateRecoverThreshold() != nil {
+		m.RecoverThreshold = 0
+	}
+	return m, true
+}
+
+// ScoreRangeFor 把打分范围收窄到 pod 的 QoS 阈值,超过阈值的节点得分最低
+func (m MetricConfig) ScoreRangeFor(class v1.PodQOSClass) MetricConfig {
+	threshold, exist := m.QoSThresholds[class]
+	if !exist {
+		return m
+	}
+	return m.ScoreRange(threshold)
+}
+
+// ScoreRange 把打分范围的上限(comparison 为 below 时为下限)设置为 threshold,按到阈值的余量打分
+func (m MetricConfig) ScoreRange(threshold float64) MetricConfig {
+	if m.Comparison == ComparisonBelow {
+		if threshold < m.ScoreMax {
+			m.ScoreMin = threshold
+		}
+	} else if threshold > m.ScoreMin {
+		m.ScoreMax = threshold
+	}
+	return m
+}
+
+func (m *MetricConfig) validateQoSThresholds() error {
+	for class := range m.QoSThresholds {
+		switch class {
+		case v1.PodQOSGuaranteed, v1.PodQOSBurstable, v1.PodQOSBestEffort:
+		default:
+			return fmt.Errorf("metric %v qosThresholds 只支持 %v/%v/%v", m.Name, v1.PodQOSGuaranteed, v1.PodQOSBurstable, v1.PodQOSBestEffort)
+		}
+	}
+	return nil
+}
+
+// setQoSThresholds 设置内置 memory 指标各个 QoS class 的阈值,为 0 或者配置文件中已经配置时不覆盖
+func (c *Config) setQoSThresholds(guaranteed, burstable, bestEffort int) {
+	i := indexMetric(c.Metrics, MemoryMetricName)
+	if i < 0 {
+		return
+	}
+	m := &c.Metrics[i]
+	for class, value := range map[v1.PodQOSClass]int{
+		v1.PodQOSGuaranteed: guaranteed,
+		v1.PodQOSBurstable:  burstable,
+		v1.PodQOSBestEffort: bestEffort,
+	} {
+		if _, exist := m.QoSThresholds[class]; exist || value == 0 {
+			continue
+		}
+		if m.QoSThresholds == nil {
+			m.QoSThresholds = make(map[v1.PodQOSClass]float64)
+		}
+		m.QoSThresholds[class] = float64(value)
+	}
+}
@@ -0,0 +1,82 @@
+package conf
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestForQoS(t *testing.T) {
+	m := MetricConfig{
+		Name:             MemoryMetricName,
+		Comparison:       ComparisonAbove,
+		Threshold:        80,
+		RecoverThreshold: 70,
+		QoSThresholds:    map[v1.PodQOSClass]float64{v1.PodQOSGuaranteed: 90, v1.PodQOSBestEffort: 60},
+	}
+
+	tests := []struct {
+		class       v1.PodQOSClass
+		want        float64
+		wantRecover float64
+		wantExists  bool
+	}{
+		{class: v1.PodQOSGuaranteed, want: 90, wantRecover: 70, wantExists: true},
+		// 低水位越过 QoS 阈值时关闭滞后
+		{class: v1.PodQOSBestEffort, want: 60, wantRecover: 0, wantExists: true},
+		{class: v1.PodQOSBurstable, want: 80, wantRecover: 70},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.class), func(t *testing.T) {
+			got, exist := m.ForQoS(tt.class)
+			if exist != tt.wantExists || got.Threshold != tt.want || got.RecoverThreshold != tt.wantRecover {
+				t.Errorf("ForQoS() = %v/%v, %v, 期望 %v/%v, %v", got.Threshold, got.RecoverThreshold, exist, tt.want, tt.wantRecover, tt.wantExists)
+			}
+		})
+	}
+}
+
+func TestScoreRangeFor(t *testing.T) {
+	qos := map[v1.PodQOSClass]float64{v1.PodQOSBestEffort: 60}
+
+	tests := []struct {
+		name       string
+		comparison string
+		class      v1.PodQOSClass
+		wantMin    float64
+		wantMax    float64
+	}{
+		{name: "above 收窄上限", comparison: ComparisonAbove, class: v1.PodQOSBestEffort, wantMin: 0, wantMax: 60},
+		{name: "below 收窄下限", comparison: ComparisonBelow, class: v1.PodQOSBestEffort, wantMin: 60, wantMax: 100},
+		{name: "没有配置该 class", comparison: ComparisonAbove, class: v1.PodQOSBurstable, wantMin: 0, wantMax: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := MetricConfig{Name: "load", Comparison: tt.comparison, ScoreMax: 100, QoSThresholds: qos}
+			got := m.ScoreRangeFor(tt.class)
+			if got.ScoreMin != tt.wantMin || got.ScoreMax != tt.wantMax {
+				t.Errorf("ScoreRangeFor() = [%v, %v], 期望 [%v, %v]", got.ScoreMin, got.ScoreMax, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestSetQoSThresholds(t *testing.T) {
+	c := &Config{Metrics: []MetricConfig{
+		{Name: MemoryMetricName, QoSThresholds: map[v1.PodQOSClass]float64{v1.PodQOSGuaranteed: 95}},
+	}}
+	c.setQoSThresholds(90, 80, 0)
+
+	want := map[v1.PodQOSClass]float64{v1.PodQOSGuaranteed: 95, v1.PodQOSBurstable: 80}
+	got := c.Metrics[0].QoSThresholds
+	if len(got) != len(want) {
+		t.Fatalf("QoSThresholds = %v, 期望 %v", got, want)
+	}
+	for class, value := range want {
+		if got[class] != value {
+			t.Errorf("%v 阈值 = %v, 期望 %v", class, got[class], value)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package conf
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReservationConfig 记录最近调度到节点上的 pod,在监控数据体现之前把它们的资源请求计入节点负载
+type ReservationConfig struct {
+	// TTL 预留的有效期,应大于监控数据的延迟,为 0 时关闭
+	TTL time.Duration
+	// SampleLag 样本时间之前 SampleLag 内调度的 pod 可能还没有体现在样本中,仍然计算预留.
+	// 数据源没有提供样本时间时只按 TTL 释放
+	SampleLag time.Duration
+}
+
+// Enabled 是否开启预留
+func (r ReservationConfig) Enabled() bool {
+	return r.TTL > 0
+}
+
+// setReservation 设置预留配置
+func (c *Config) setReservation(ttl, sampleLag time.Duration) error {
+	if ttl < 0 {
+		return fmt.Errorf("reservation_ttl 不能小于 0")
+	}
+	if sampleLag < 0 {
+		return fmt.Errorf("reservation_sample_lag 不能小于 0")
+	}
+
+	c.Reservation = ReservationConfig{
+		TTL:       ttl,
+		SampleLag: sampleLag,
+	}
+	return nil
+}
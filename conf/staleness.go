@@ -0,0 +1,39 @@
+package conf
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// StalePolicyAllow 数据过期或缺失时节点通过预选
+	StalePolicyAllow = "allow"
+	// StalePolicyReject 数据过期或缺失时节点不通过预选
+	StalePolicyReject = "reject"
+	// StalePolicyKeepLast 数据过期后在 GracePeriod 内继续使用最后一次的值,之后通过预选
+	StalePolicyKeepLast = "keep-last"
+)
+
+// StalenessConfig 节点负载数据过期或缺失时的预选策略
+type StalenessConfig struct {
+	Policy      string
+	GracePeriod time.Duration
+}
+
+// setStalePolicy 设置数据过期策略
+func (c *Config) setStalePolicy(policy string, gracePeriod time.Duration) error {
+	switch policy {
+	case StalePolicyAllow, StalePolicyReject, StalePolicyKeepLast:
+	default:
+		return fmt.Errorf("stale_policy 只支持 %v/%v/%v", StalePolicyAllow, StalePolicyReject, StalePolicyKeepLast)
+	}
+	if gracePeriod < 0 {
+		return fmt.Errorf("stale_grace_period 不能小于 0")
+	}
+
+	c.Staleness = StalenessConfig{
+		Policy:      policy,
+		GracePeriod: gracePeriod,
+	}
+	return nil
+}
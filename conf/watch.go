@@ -0,0 +1,48 @@
+package conf
+
+import (
+	"github.com/prometheus/common/log"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"kube-scheduler-extender/metrics"
+	"time"
+)
+
+// Watch 每隔 interval 检查配置文件,内容变化后按 o 重新生成配置并交给 reload 检查和替换.
+// 生成或 reload 失败时记录日志并保留当前配置,同一份文件内容只尝试一次
+func Watch(o Options, interval time.Duration, reload func(*Config) error, stopCh <-chan struct{}) {
+	if len(o.Files()) == 0 || interval <= 0 {
+		return
+	}
+
+	last, err := o.Checksum()
+	if err != nil {
+		log.Errorf("读取配置文件出错: %v", err)
+	}
+	log.Infof("每 %v 检查配置文件 %v", interval, o.Files())
+
+	go wait.Until(func() {
+		sum, err := o.Checksum()
+		if err != nil {
+			// ConfigMap 更新期间可能短暂读不到文件,下次再检查
+			log.Errorf("读取配置文件出错: %v", err)
+			return
+		}
+		if sum == last {
+			return
+		}
+		last = sum
+
+		c, err := Build(o)
+		if err == nil {
+			err = reload(c)
+		}
+		if err != nil {
+			log.Errorf("配置文件变化,新配置不合法,继续使用当前配置: %v", err)
+			metrics.ConfigReloads.WithLabelValues("failure").Inc()
+			return
+		}
+		log.Infoln("配置文件变化,新配置已生效")
+		metrics.ConfigReloads.WithLabelValues("success").Inc()
+		metrics.ConfigLastReloadSuccess.SetToCurrentTime()
+	}, interval, stopCh)
+}
@@ -0,0 +1,69 @@
+package conf
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	o := testOptions()
+	o.ConfigFile = writeFile(t, dir, "version: v1\nthresholds:\n  memory: 70\n")
+
+	reloaded := make(chan *Config, 10)
+	// memory 阈值为 50 的配置被 reload 拒绝
+	var rejected int32
+	reload := func(c *Config) error {
+		if c.Metrics[indexMetric(c.Metrics, MemoryMetricName)].Threshold == 50 {
+			atomic.AddInt32(&rejected, 1)
+			return errors.New("rejected")
+		}
+		reloaded <- c
+		return nil
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	Watch(o, 10*time.Millisecond, reload, stopCh)
+
+	expectReload := func(threshold float64) {
+		t.Helper()
+		select {
+		case c := <-reloaded:
+			if m := c.Metrics[indexMetric(c.Metrics, MemoryMetricName)]; m.Threshold != threshold {
+				t.Fatalf("热加载后 memory 阈值 %v, 期望 %v", m.Threshold, threshold)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("配置文件变化后没有热加载")
+		}
+	}
+	expectNoReload := func() {
+		t.Helper()
+		select {
+		case c := <-reloaded:
+			t.Fatalf("不应该热加载, 新配置 %+v", c)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// 文件没有变化时不热加载
+	expectNoReload()
+
+	writeFile(t, dir, "version: v1\nthresholds:\n  memory: 60\n")
+	expectReload(60)
+
+	// 不合法的配置不交给 reload
+	writeFile(t, dir, "version: v2\n")
+	expectNoReload()
+
+	// reload 拒绝的配置不生效,同一份内容不重复尝试
+	writeFile(t, dir, "version: v1\nthresholds:\n  memory: 50\n")
+	expectNoReload()
+	if n := atomic.LoadInt32(&rejected); n != 1 {
+		t.Fatalf("同一份配置尝试了 %v 次, 期望 1 次", n)
+	}
+
+	writeFile(t, dir, "version: v1\nthresholds:\n  memory: 40\n")
+	expectReload(40)
+}
@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"kube-scheduler-extender/conf"
+	"sort"
+	"time"
+)
+
+// NodeFilter 选择返回哪些 node 和指标的缓存,字段为空时不过滤
+type NodeFilter struct {
+	Names    []string
+	Selector labels.Selector
+	Metric   string
+}
+
+// CachedMetric 一个 node 一个指标的缓存数据,以及不考虑 pod 时的预选状态
+type CachedMetric struct {
+	Value float64 `json:"value"`
+	// Reserved 调度预留增加的值
+	Reserved   float64   `json:"reserved,omitempty"`
+	Capacity   float64   `json:"capacity,omitempty"`
+	CheckTime  time.Time `json:"checkTime"`
+	AgeSeconds float64   `json:"ageSeconds"`
+	// Stale 超过 overdueTime 没有更新
+	Stale bool `json:"stale"`
+	// Excluded 开启滞后时,超过高水位后尚未回落到低水位
+	Excluded  bool    `json:"excluded"`
+	Threshold float64 `json:"threshold"`
+	// Filtered 按 node 阈值、高低水位和过期策略,该指标的预选是否过滤此 node
+	Filtered bool `json:"filtered"`
+}
+
+// CachedNode 一个 node 所有指标的缓存数据
+type CachedNode struct {
+	Name    string                  `json:"name"`
+	Metrics map[string]CachedMetric `json:"metrics"`
+}
+
+// MetricHealth 一个指标从数据源查询的状态
+type MetricHealth struct {
+	Metric      string    `json:"metric"`
+	LastSuccess time.Time `json:"lastSuccess"`
+	// Degraded 超过 overdueTime 没有成功查询
+	Degraded bool `json:"degraded"`
+	// State normal、backoff 或 open(熔断)
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	NextQuery           time.Time `json:"nextQuery"`
+	// LastError 最近一次查询失败的原因
+	LastError   string `json:"lastError,omitempty"`
+	CachedNodes int    `json:"cachedNodes"`
+}
+
+// SourceHealth 数据源和每个指标的查询状态
+type SourceHealth struct {
+	DataSource string         `json:"dataSource"`
+	Degraded   bool           `json:"degraded"`
+	Metrics    []MetricHealth `json:"metrics"`
+}
+
+// Snapshot 按 filter 返回缓存中的 node 数据,按 node 名排序.
+// 按 label 过滤需要 node informer
+func (n *Nodes) Snapshot(filter NodeFilter) ([]CachedNode, error) {
+	var selected map[string]bool
+	if len(filter.Names) != 0 {
+		selected = make(map[string]bool)
+		for _, name := range filter.Names {
+			selected[name] = true
+		}
+	}
+	if filter.Selector != nil && !filter.Selector.Empty() {
+		if nodeLister == nil {
+			return nil, errors.New("按 label 过滤需要 node informer, 开启 node_lookup、reservation、preempt 或 node 阈值后可用")
+		}
+		list, err := nodeLister.List(filter.Selector)
+		if err != nil {
+			return nil, err
+		}
+		matched := make(map[string]bool, len(list))
+		for _, node := range list {
+			if selected == nil || selected[node.Name] {
+				matched[node.Name] = true
+			}
+		}
+		selected = matched
+	}
+
+	c := conf.Get()
+	now := time.Now()
+	result := make(map[string]*CachedNode)
+
+	n.Lock.RLock()
+	defer n.Lock.RUnlock()
+	for _, m := range c.Metrics {
+		if filter.Metric != "" && filter.Metric != m.Name {
+			continue
+		}
+		for nodeName, v := range n.NodeMetrics[m.Name] {
+			if selected != nil && !selected[nodeName] {
+				continue
+			}
+			node, exist := result[nodeName]
+			if !exist {
+				node = &CachedNode{Name: nodeName, Metrics: make(map[string]CachedMetric)}
+				result[nodeName] = node
+			}
+			node.Metrics[m.Name] = cachedMetric(MetricForNode(m, nil, nodeName), nodeName, v, c.Staleness.Policy, now)
+		}
+	}
+
+	nodes := make([]CachedNode, 0, len(result))
+	for _, node := range result {
+		nodes = append(nodes, *node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes, nil
+}
+
+// cachedMetric 与 load 预选的判断保持一致,只是不考虑 pod 的 annotation 和 QoS 阈值
+func cachedMetric(m conf.MetricConfig, nodeName string, v *NodeMetric, policy string, now time.Time) CachedMetric {
+	reserved := v.Reserved(m.Name, nodeName, now)
+	cached := CachedMetric{
+		Value:      v.Value,
+		Reserved:   reserved,
+		Capacity:   v.Capacity,
+		CheckTime:  v.CheckTime,
+		AgeSeconds: now.Sub(v.CheckTime).Seconds(),
+		Stale:      !v.Fresh(now),
+		Excluded:   v.Excluded,
+		Threshold:  m.Threshold,
+	}
+	if v.Usable(now) {
+		cached.Filtered = m.Exceeds(v.Value+reserved) || (m.Hysteresis() && v.Excluded)
+	} else {
+		cached.Filtered = policy == conf.StalePolicyReject
+	}
+	return cached
+}
+
+// Health 返回数据源每个指标的查询状态
+func (n *Nodes) Health() SourceHealth {
+	now := time.Now()
+	overdue := overdueTime()
+	health := SourceHealth{DataSource: n.source.Name()}
+
+	n.Lock.RLock()
+	defer n.Lock.RUnlock()
+	for _, m := range conf.Get().Metrics {
+		metric := MetricHealth{
+			Metric:      m.Name,
+			LastSuccess: n.lastSuccess[m.Name],
+			Degraded:    now.Sub(n.lastSuccess[m.Name]) > overdue,
+			State:       backoffStateNames[backoffStateNormal],
+			CachedNodes: len(n.NodeMetrics[m.Name]),
+		}
+		if h, exist := n.health[m.Name]; exist {
+			metric.State = backoffStateNames[h.state]
+			metric.ConsecutiveFailures = h.failures
+			metric.NextQuery = h.next
+			metric.LastError = h.lastError
+		}
+		health.Degraded = health.Degraded || metric.Degraded
+		health.Metrics = append(health.Metrics, metric)
+	}
+	return health
+}
+
+// NodeNames 返回所有 node 名, 有 node informer 时从 informer 缓存读取, 否则返回指标缓存中的 node
+func (n *Nodes) NodeNames() ([]string, error) {
+	var names []string
+	if nodeLister != nil {
+		list, err := nodeLister.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range list {
+			names = append(names, node.Name)
+		}
+	} else {
+		seen := make(map[string]bool)
+		n.Lock.RLock()
+		for _, nodes := range n.NodeMetrics {
+			for name := range nodes {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+		n.Lock.RUnlock()
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GetPod 查询 pod, 有 pod informer 时从 informer 缓存读取, 否则通过 API server 查询
+func GetPod(namespace, name string) (*v1.Pod, error) {
+	if podLister != nil {
+		return podLister.Pods(namespace).Get(name)
+	}
+	if KubeClient == nil {
+		return nil, errors.New("没有 k8s client, 只能在请求中传递 pod")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return KubeClient.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"kube-scheduler-extender/conf"
+	"math"
+	"sort"
+)
+
+// aggregate 把 range 查询窗口内按时间排序的样本聚合为一个值
+func aggregate(r conf.RangeConfig, values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	switch r.Aggregation {
+	case conf.AggregationAvg:
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	case conf.AggregationMax:
+		max := values[0]
+		for _, v := range values[1:] {
+			max = math.Max(max, v)
+		}
+		return max
+	case conf.AggregationEWMA:
+		s := values[0]
+		for _, v := range values[1:] {
+			s = r.Alpha*v + (1-r.Alpha)*s
+		}
+		return s
+	}
+
+	p, _ := r.Percentile()
+	return percentile(values, p)
+}
+
+// percentile 按 nearest-rank 计算分位数
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"encoding/json"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/util"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAggregate(t *testing.T) {
+	values := []float64{10, 40, 20, 30}
+
+	tests := []struct {
+		name        string
+		aggregation string
+		alpha       float64
+		values      []float64
+		want        float64
+	}{
+		{name: "avg", aggregation: conf.AggregationAvg, values: values, want: 25},
+		{name: "max", aggregation: conf.AggregationMax, values: values, want: 40},
+		{name: "ewma", aggregation: conf.AggregationEWMA, alpha: 0.5, values: values, want: 26.25},
+		{name: "ewma alpha 为 1 时为最新样本", aggregation: conf.AggregationEWMA, alpha: 1, values: values, want: 30},
+		{name: "p50", aggregation: "p50", values: values, want: 20},
+		{name: "p95", aggregation: conf.AggregationP95, values: values, want: 40},
+		{name: "p1 取最小值", aggregation: "p1", values: values, want: 10},
+		{name: "单个样本", aggregation: "p90", values: []float64{5}, want: 5},
+		{name: "没有样本", aggregation: conf.AggregationAvg, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]float64{}, tt.values...)
+			got := aggregate(conf.RangeConfig{Aggregation: tt.aggregation, Alpha: tt.alpha}, input)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("aggregate() = %v, 期望 %v", got, tt.want)
+			}
+			for i := range input {
+				if input[i] != tt.values[i] {
+					t.Fatalf("aggregate() 修改了样本顺序: %v", input)
+				}
+			}
+		})
+	}
+}
+
+// testRangeSeries 一个 range 查询结果序列
+type testRangeSeries struct {
+	instance string
+	values   []float64
+}
+
+// testPrometheusRange 返回 query_range 请求的 matrix 结果,样本间隔 30s
+func testPrometheusRange(t *testing.T, series ...testRangeSeries) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_range" {
+			t.Errorf("请求路径 %v, 期望 /api/v1/query_range", r.URL.Path)
+		}
+		result := make([]map[string]interface{}, 0, len(series))
+		for _, s := range series {
+			samples := make([][]interface{}, 0, len(s.values))
+			for i, v := range s.values {
+				samples = append(samples, []interface{}{1600000000 + 30*i, strconv.FormatFloat(v, 'f', -1, 64)})
+			}
+			result = append(result, map[string]interface{}{
+				"metric": map[string]string{"instance": s.instance},
+				"values": samples,
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"resultType": "matrix", "result": result},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPrometheusRangeFetch(t *testing.T) {
+	server := testPrometheusRange(t,
+		testRangeSeries{instance: "node-1", values: []float64{10, 40, 20, 30}},
+		testRangeSeries{instance: "node-2", values: []float64{50}},
+	)
+	setTestConfig(t, &conf.Config{
+		PrometheusUrls: []string{server.URL},
+		NodeName:       conf.NodeNameConfig{Label: "instance"},
+	})
+
+	source, err := NewPrometheusSource(util.HTTPClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		aggregation string
+		want        map[string]float64
+	}{
+		{aggregation: conf.AggregationAvg, want: map[string]float64{"node-1": 25, "node-2": 50}},
+		{aggregation: conf.AggregationMax, want: map[string]float64{"node-1": 40, "node-2": 50}},
+		{aggregation: conf.AggregationP95, want: map[string]float64{"node-1": 40, "node-2": 50}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.aggregation, func(t *testing.T) {
+			m := conf.MetricConfig{
+				Name:  "load",
+				Query: "load",
+				Range: &conf.RangeConfig{Window: 5 * time.Minute, Step: 30 * time.Second, Aggregation: tt.aggregation},
+			}
+			got, err := source.Fetch(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Fetch() = %v, 期望 %v", got, tt.want)
+			}
+			for nodeName, want := range tt.want {
+				if math.Abs(got[nodeName]-want) > 1e-9 {
+					t.Errorf("node %v 指标值 %v, 期望 %v", nodeName, got[nodeName], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrometheusRangeFetchDuplicateSeries(t *testing.T) {
+	// node-1 有两个 series,分别聚合后取最接近过滤条件的值,而不是把样本混在一起聚合
+	server := testPrometheusRange(t,
+		testRangeSeries{instance: "node-1", values: []float64{10, 20}},
+		testRangeSeries{instance: "node-1", values: []float64{80, 90}},
+		testRangeSeries{instance: "node-2", values: []float64{50}},
+	)
+	setTestConfig(t, &conf.Config{
+		PrometheusUrls: []string{server.URL},
+		NodeName:       conf.NodeNameConfig{Label: "instance"},
+	})
+
+	source, err := NewPrometheusSource(util.HTTPClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		comparison string
+		want       map[string]float64
+	}{
+		{comparison: conf.ComparisonAbove, want: map[string]float64{"node-1": 85, "node-2": 50}},
+		{comparison: conf.ComparisonBelow, want: map[string]float64{"node-1": 15, "node-2": 50}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.comparison, func(t *testing.T) {
+			m := conf.MetricConfig{
+				Name:       "load",
+				Query:      "load",
+				Comparison: tt.comparison,
+				Range:      &conf.RangeConfig{Window: 5 * time.Minute, Step: 30 * time.Second, Aggregation: conf.AggregationAvg},
+			}
+			got, err := source.Fetch(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Fetch() = %v, 期望 %v", got, tt.want)
+			}
+			for nodeName, want := range tt.want {
+				if math.Abs(got[nodeName]-want) > 1e-9 {
+					t.Errorf("node %v 指标值 %v, 期望 %v", nodeName, got[nodeName], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"github.com/prometheus/common/log"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/metrics"
+	"time"
+)
+
+const (
+	// 指标查询的退避状态,对应 data_source_backoff_state
+	backoffStateNormal  = 0
+	backoffStateBackoff = 1
+	backoffStateOpen    = 2
+)
+
+var backoffStateNames = map[int]string{
+	backoffStateNormal:  "normal",
+	backoffStateBackoff: "backoff",
+	backoffStateOpen:    "open",
+}
+
+// fetchHealth 一个指标最近的查询状态
+type fetchHealth struct {
+	state     int
+	failures  int
+	next      time.Time
+	lastError string
+}
+
+// loop 执行 f, 等待 f 返回的时间后再次执行,直到 stopCh 关闭. 等待时间按当前配置随机增加 jitter
+func loop(stopCh <-chan struct{}, f func() time.Duration) {
+	for {
+		d := f()
+		if j := conf.Get().Intervals.Jitter; j > 0 {
+			d = wait.Jitter(d, j)
+		}
+
+		t := time.NewTimer(d)
+		select {
+		case <-stopCh:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// poll 按 Refresh 周期查询一个指标直到 ctx 取消. 连续失败时周期翻倍退避,
+// 达到 BreakerThreshold 次后熔断 BreakerCooldown, 之后再尝试一次,成功则恢复正常周期
+func (n *Nodes) poll(ctx context.Context, name string) {
+	var failures int
+	loop(ctx.Done(), func() time.Duration {
+		err := n.fetchMetric(name)
+		intervals := conf.Get().Intervals
+		if err == nil {
+			if failures > 0 {
+				log.Infof("指标 %v 查询恢复,此前连续失败 %d 次", name, failures)
+			}
+			failures = 0
+			n.setBackoffState(name, backoffStateNormal, failures, intervals.Refresh, nil)
+			return intervals.Refresh
+		}
+
+		failures++
+		delay, open := intervals.Backoff(failures)
+		state := backoffStateNormal
+		switch {
+		case open:
+			log.Warnf("指标 %v 连续 %d 次查询失败: %v, 熔断 %v 后再尝试", name, failures, err, delay)
+			state = backoffStateOpen
+		case failures > 1:
+			log.Warnf("指标 %v 连续 %d 次查询失败: %v, %v 后重试", name, failures, err, delay)
+			state = backoffStateBackoff
+		}
+		n.setBackoffState(name, state, failures, delay, err)
+		return delay
+	})
+}
+
+// setBackoffState 记录指标的查询状态,指标已被删除时不记录
+func (n *Nodes) setBackoffState(name string, state, failures int, delay time.Duration, err error) {
+	n.Lock.Lock()
+	defer n.Lock.Unlock()
+	h, exist := n.health[name]
+	if !exist {
+		return
+	}
+	h.state, h.failures, h.next = state, failures, time.Now().Add(delay)
+	if err != nil {
+		h.lastError = err.Error()
+	}
+
+	metrics.DataSourceBackoffState.WithLabelValues(name).Set(float64(state))
+	metrics.DataSourceBackoffSeconds.WithLabelValues(name).Set(delay.Seconds())
+}
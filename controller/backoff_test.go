@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"kube-scheduler-extender/conf"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testSource 按 results 依次返回查询结果, results 用完之后一直失败.
+// 每次查询开始时记录上一次查询之后的退避状态,第 want 次查询时关闭 done 并等待 stop
+type testSource struct {
+	n       *Nodes
+	results []error
+	calls   int
+	seen    []fetchHealth
+	want    int
+	done    chan struct{}
+	stop    <-chan struct{}
+}
+
+func (s *testSource) Name() string {
+	return "test"
+}
+
+func (s *testSource) Supports(m conf.MetricConfig) bool {
+	return true
+}
+
+func (s *testSource) Fetch(m conf.MetricConfig) (map[string]float64, error) {
+	if s.calls > 0 {
+		s.n.Lock.RLock()
+		s.seen = append(s.seen, *s.n.health[m.Name])
+		s.n.Lock.RUnlock()
+	}
+	s.calls++
+	if s.calls == s.want {
+		close(s.done)
+		<-s.stop
+	}
+
+	err := errors.New("unavailable")
+	if s.calls <= len(s.results) {
+		err = s.results[s.calls-1]
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]float64{"node-1": 50}, nil
+}
+
+func TestPollBackoff(t *testing.T) {
+	fail := errors.New("unavailable")
+
+	tests := []struct {
+		name    string
+		results []error
+		// want 第 2 次开始每次查询之前的状态和连续失败次数
+		want []fetchHealth
+	}{
+		{
+			name:    "连续失败后退避并熔断",
+			results: []error{fail, fail, fail, fail},
+			want: []fetchHealth{
+				{state: backoffStateNormal, failures: 1},
+				{state: backoffStateBackoff, failures: 2},
+				{state: backoffStateOpen, failures: 3},
+				{state: backoffStateOpen, failures: 4},
+			},
+		},
+		{
+			name:    "成功后恢复正常周期",
+			results: []error{fail, fail, nil, fail},
+			want: []fetchHealth{
+				{state: backoffStateNormal, failures: 1},
+				{state: backoffStateBackoff, failures: 2},
+				{state: backoffStateNormal},
+				{state: backoffStateNormal, failures: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, &conf.Config{
+				Metrics: []conf.MetricConfig{{Name: "load"}},
+				Intervals: conf.IntervalsConfig{
+					Refresh:          time.Millisecond,
+					BackoffMax:       4 * time.Millisecond,
+					BreakerThreshold: 3,
+					BreakerCooldown:  10 * time.Millisecond,
+				},
+			})
+			n := &Nodes{
+				NodeMetrics: map[string]map[string]*NodeMetric{"load": {}},
+				lastSuccess: map[string]time.Time{},
+				health:      map[string]*fetchHealth{"load": {}},
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			source := &testSource{n: n, results: tt.results, want: len(tt.want) + 1, done: make(chan struct{}), stop: ctx.Done()}
+			n.source = source
+
+			go n.poll(ctx, "load")
+			select {
+			case <-source.done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("5s 内没有完成 %v 次查询", source.want)
+			}
+
+			got := make([]fetchHealth, 0, len(source.seen))
+			for _, h := range source.seen {
+				got = append(got, fetchHealth{state: h.state, failures: h.failures})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("退避状态 %+v, 期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"context"
+	"github.com/prometheus/common/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	extender "k8s.io/kube-scheduler/extender/v1"
+	"time"
+)
+
+// Bind 创建 pod 到 node 的 Binding,成功后记录预留
+func Bind(client kubernetes.Interface, args extender.ExtenderBindingArgs) error {
+	binding := &v1.Binding{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: args.PodNamespace,
+			Name:      args.PodName,
+			UID:       args.PodUID,
+		},
+		Target: v1.ObjectReference{
+			Kind: "Node",
+			Name: args.Node,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := client.CoreV1().Pods(args.PodNamespace).Bind(ctx, binding, metav1.CreateOptions{}); err != nil {
+		log.Errorf("pod %v/%v 绑定 node %v 出错: %v", args.PodNamespace, args.PodName, args.Node, err)
+		return err
+	}
+	log.Infof("pod %v/%v 绑定 node %v", args.PodNamespace, args.PodName, args.Node)
+
+	// pod informer 收到更新之前先记录预留,避免这段时间内继续调度到同一个 node
+	if podLister != nil {
+		pod, err := podLister.Pods(args.PodNamespace).Get(args.PodName)
+		if err == nil && pod.UID == args.PodUID {
+			PodReservations.Reserve(pod, args.Node, time.Now())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+	extender "k8s.io/kube-scheduler/extender/v1"
+	"kube-scheduler-extender/conf"
+	"testing"
+	"time"
+)
+
+func TestBind(t *testing.T) {
+	pod := newTestPod("default", "web", "uid-web", "1Gi", "500m")
+	args := extender.ExtenderBindingArgs{
+		PodNamespace: pod.Namespace,
+		PodName:      pod.Name,
+		PodUID:       pod.UID,
+		Node:         "node-1",
+	}
+
+	tests := []struct {
+		name         string
+		bindErr      error
+		podUID       string
+		wantErr      bool
+		wantReserved bool
+	}{
+		{name: "绑定成功记录预留", podUID: "uid-web", wantReserved: true},
+		{name: "绑定失败返回错误", podUID: "uid-web", bindErr: errors.New("binding forbidden"), wantErr: true},
+		{name: "缓存中 pod uid 不一致不记录预留", podUID: "uid-other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, &conf.Config{Reservation: conf.ReservationConfig{TTL: time.Minute}})
+			cached := pod.DeepCopy()
+			cached.UID = types.UID(tt.podUID)
+			setTestPodLister(t, cached)
+			old := PodReservations
+			PodReservations = NewReservations()
+			defer func() { PodReservations = old }()
+
+			var binding *v1.Binding
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "binding" {
+					return false, nil, nil
+				}
+				binding = action.(core.CreateAction).GetObject().(*v1.Binding)
+				return true, nil, tt.bindErr
+			})
+
+			err := Bind(client, args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Bind() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if binding == nil {
+				t.Fatal("没有创建 Binding")
+			}
+			if binding.Namespace != args.PodNamespace || binding.Name != args.PodName || binding.UID != args.PodUID {
+				t.Errorf("Binding 对象 %v/%v (%v), 期望 %v/%v (%v)", binding.Namespace, binding.Name, binding.UID, args.PodNamespace, args.PodName, args.PodUID)
+			}
+			if binding.Target.Kind != "Node" || binding.Target.Name != args.Node {
+				t.Errorf("Binding target %v/%v, 期望 Node/%v", binding.Target.Kind, binding.Target.Name, args.Node)
+			}
+
+			v, reserved := PodReservations.pods[pod.UID]
+			if reserved != tt.wantReserved {
+				t.Fatalf("预留 = %v, 期望 %v", reserved, tt.wantReserved)
+			}
+			if reserved && (v.nodeName != args.Node || v.memory != 1<<30 || v.cpu != 500) {
+				t.Errorf("预留 node %v, memory %v, cpu %vm, 期望 node %v, memory %v, cpu 500m", v.nodeName, v.memory, v.cpu, args.Node, 1<<30)
+			}
+		})
+	}
+}
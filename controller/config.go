@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"kube-scheduler-extender/conf"
+	"strings"
+)
+
+// CheckConfig 检查数据源是否支持配置中的指标,启动和热加载时调用
+func CheckConfig(source DataSource, c *conf.Config) error {
+	for _, m := range c.Metrics {
+		if !source.Supports(m) {
+			return fmt.Errorf("数据源 %v 不支持指标 %v", source.Name(), m.Name)
+		}
+		if _, ok := source.(CapacitySource); c.CapacityRequired(m) && !ok {
+			return fmt.Errorf("数据源 %v 不支持查询指标 %v 的容量", source.Name(), m.Name)
+		}
+		if m.Range != nil && source.Name() != PrometheusDataSource {
+			log.Warnf("数据源 %v 不支持 range 查询,指标 %v 使用瞬时值", source.Name(), m.Name)
+		}
+	}
+	return nil
+}
+
+// NeedNodeInformer 配置是否需要 node informer
+func NeedNodeInformer(c *conf.Config) bool {
+	return c.NodeName.Lookup || c.Reservation.Enabled() || c.NodeThresholdsEnabled()
+}
+
+// CheckReload 检查新配置依赖的 informer 是否已经启动,需要启动新 informer 的修改只能重启生效
+func CheckReload(c *conf.Config) error {
+	if changed := c.DataSource.Changed(conf.Get().DataSource); len(changed) != 0 {
+		return fmt.Errorf("数据源配置 dataSource.%v 修改需要重启生效", strings.Join(changed, ", dataSource."))
+	}
+	if NeedNodeInformer(c) && nodeLister == nil {
+		return errors.New("node_lookup、reservation、按 node 设置阈值需要 node informer,启动时没有开启,需要重启生效")
+	}
+	if c.Reservation.Enabled() && podLister == nil {
+		return errors.New("reservation 需要 pod informer,启动时没有开启,需要重启生效")
+	}
+	return nil
+}
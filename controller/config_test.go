@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"kube-scheduler-extender/conf"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckConfig(t *testing.T) {
+	source := NewMetricsServerSource(nil, nil, time.Second)
+
+	tests := []struct {
+		name    string
+		metrics []conf.MetricConfig
+		wantErr string
+	}{
+		{name: "内置指标", metrics: []conf.MetricConfig{{Name: conf.MemoryMetricName}, {Name: conf.CPUMetricName}}},
+		{name: "range 查询只记录日志", metrics: []conf.MetricConfig{{Name: conf.CPUMetricName, Range: &conf.RangeConfig{Window: time.Minute}}}},
+		{name: "不支持的指标", metrics: []conf.MetricConfig{{Name: conf.MemoryMetricName}, {Name: "load"}}, wantErr: "不支持指标 load"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckConfig(source, &conf.Config{Metrics: tt.metrics})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckConfig() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CheckConfig() error = %v, 期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckReload(t *testing.T) {
+	current := &conf.Config{
+		DataSource: conf.DataSourceConfig{
+			Type:    conf.PrometheusDataSource,
+			Timeout: 30 * time.Second,
+			Kubelet: conf.KubeletConfig{Access: conf.KubeletAccessProxy, Port: 10250, Workers: 16},
+		},
+	}
+
+	tests := []struct {
+		name string
+		// informer 是否已经启动
+		informers bool
+		modify    func(c *conf.Config)
+		wantErr   string
+	}{
+		{name: "没有修改", modify: func(c *conf.Config) {}},
+		{name: "修改阈值等可以热加载的配置", modify: func(c *conf.Config) {
+			c.Staleness.Policy = conf.StalePolicyReject
+		}},
+		{name: "修改数据源类型", modify: func(c *conf.Config) {
+			c.DataSource.Type = conf.KubeletDataSource
+		}, wantErr: "dataSource.type 修改需要重启生效"},
+		{name: "修改超时和 headers", modify: func(c *conf.Config) {
+			c.DataSource.Timeout = time.Second
+			c.DataSource.Prometheus.Headers = map[string]string{"X-Scope-OrgID": "tenant"}
+		}, wantErr: "dataSource.timeout, dataSource.prometheus.headers 修改需要重启生效"},
+		{name: "开启预留但没有 informer", modify: func(c *conf.Config) {
+			c.Reservation.TTL = time.Minute
+		}, wantErr: "需要 node informer"},
+		{name: "开启 node annotation 阈值但没有 informer", modify: func(c *conf.Config) {
+			c.NodeThresholdAnnotations = true
+		}, wantErr: "需要 node informer"},
+		{name: "开启预留且 informer 已启动", informers: true, modify: func(c *conf.Config) {
+			c.Reservation.TTL = time.Minute
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, current)
+			setTestNodeLister(t)
+			setTestPodLister(t)
+			if !tt.informers {
+				nodeLister, podLister = nil, nil
+			}
+			c := *current
+			tt.modify(&c)
+
+			err := CheckReload(&c)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckReload() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("CheckReload() error = %v, 期望包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"kube-scheduler-extender/conf"
+)
+
+const (
+	// PrometheusDataSource 从 prometheus 查询 PromQL
+	PrometheusDataSource = conf.PrometheusDataSource
+	// MetricsServerDataSource 从 metrics.k8s.io (metrics-server) 查询 NodeMetrics
+	MetricsServerDataSource = conf.MetricsServerDataSource
+)
+
+// DataSource 节点负载数据源, Nodes 定时调用 Fetch 刷新缓存
+type DataSource interface {
+	// Name 数据源名字,用于日志
+	Name() string
+	// Supports 判断数据源能否提供该指标
+	Supports(m conf.MetricConfig) bool
+	// Fetch 查询一个指标,返回 node 名 -> 指标值
+	Fetch(m conf.MetricConfig) (map[string]float64, error)
+}
+
+// SampleSource 可以同时提供样本时间的数据源,样本时间用于判断调度预留是否已经体现在数据中
+type SampleSource interface {
+	// FetchSamples 查询一个指标,返回 node 名 -> 指标值和最新样本的时间
+	FetchSamples(m conf.MetricConfig) (map[string]Sample, error)
+}
+
+// CapacitySource 可以同时提供节点容量的数据源,容量为指标 100% 对应的绝对值,例如内存 byte
+type CapacitySource interface {
+	// FetchCapacity 查询一个指标对应的节点容量,返回 node 名 -> 容量
+	FetchCapacity(m conf.MetricConfig) (map[string]float64, error)
+}
@@ -1,18 +1,23 @@
 package controller
 
 import (
+	"encoding/json"
 	"github.com/prometheus/common/log"
 	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/util"
 	"kube-scheduler-extender/util/debugger"
 	"os"
 	"os/signal"
-	"strconv"
 	"strings"
 )
 
-// ListenForSignal starts a goroutine that will trigger the node info
-// behavior when the process receives SIGINT (Windows) or SIGUSER2 (non-Windows).
+// ListenForSignal starts a goroutine that will log the node info when the process
+// receives SIGUSER2 (non-Windows). Windows has no spare signal, use the admin API.
 func ListenForSignal(stopCh <-chan struct{}) {
+	if debugger.CompareSignal == nil {
+		return
+	}
+
 	ch := make(chan os.Signal, 1)
 
 	signal.Notify(ch, debugger.CompareSignal)
@@ -23,20 +28,29 @@ func ListenForSignal(stopCh <-chan struct{}) {
 			case <-stopCh:
 				return
 			case <-ch:
-				log.Infof("当前prometheus_url: %v, prometheus_memory_metrics: %v, prometheus_memory_threshold: %v",
-					conf.Conf.PrometheusUrl, conf.Conf.PrometheusMemoryMetrics, conf.Conf.PrometheusMemoryThreshold)
-				builder := strings.Builder{}
+				c := conf.Get()
+				urls := make([]string, 0, len(c.PrometheusUrls))
+				for _, u := range c.PrometheusUrls {
+					urls = append(urls, util.RedactURL(u))
+				}
+				log.Infof("当前prometheus_url: %v, prometheus_mode: %v, prometheus_merge_strategy: %v",
+					strings.Join(urls, ","), c.Prometheus.Mode, c.Prometheus.MergeStrategy)
 
-				NodeInfo.Lock.RLock()
-				for nodeName, node := range NodeInfo.NodeMem {
-					builder.WriteString("\nnodeName:" + nodeName + "; memoryValue:" + strconv.Itoa(node.Value) + "; checkTime:" + node.CheckTime.Format("2006-01-02 15:04:05") + ";")
+				for _, m := range c.Metrics {
+					log.Infof("metric: %v, plugin: %v, query: %v, threshold: %v, comparison: %v",
+						m.Name, m.Plugin, m.Query, m.Threshold, m.Comparison)
 				}
 
-				info := builder.String()
-				log.Infoln("cache node number: ", strconv.Itoa(len(NodeInfo.NodeMem)))
-				log.Infoln("node info: ", info)
+				health, _ := json.Marshal(NodeInfo.Health())
+				log.Infoln("data source: ", string(health))
 
-				NodeInfo.Lock.RUnlock()
+				// 不按 label 过滤,不会出错
+				nodes, _ := NodeInfo.Snapshot(NodeFilter{})
+				for _, node := range nodes {
+					b, _ := json.Marshal(node)
+					log.Infoln("node info: ", string(b))
+				}
+				log.Infoln("cache node number: ", len(nodes))
 			}
 		}
 	}()
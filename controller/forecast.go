@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"kube-scheduler-extender/conf"
+	"time"
+)
+
+// Sample 一次从数据源查询到的节点指标值
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// appendHistory 把新样本追加到历史末尾,只保留最近 size 个,返回新的 slice 避免和旧数据共享底层数组
+func appendHistory(history []Sample, s Sample, size int) []Sample {
+	if len(history) >= size {
+		history = history[len(history)-size+1:]
+	}
+	result := make([]Sample, 0, len(history)+1)
+	result = append(result, history...)
+	return append(result, s)
+}
+
+// Forecast 根据历史样本预测 f.Horizon 之后的指标值,样本不足 2 个时返回 false
+func (m *NodeMetric) Forecast(f conf.ForecastConfig) (float64, bool) {
+	if len(m.History) < 2 {
+		return 0, false
+	}
+	if f.Method == conf.ForecastHolt {
+		return holtForecast(m.History, f), true
+	}
+	return linearForecast(m.History, f.Horizon), true
+}
+
+// linearForecast 最小二乘线性回归,以最新样本时间为原点外推 horizon
+func linearForecast(history []Sample, horizon time.Duration) float64 {
+	last := history[len(history)-1].Time
+	n := float64(len(history))
+
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range history {
+		x := s.Time.Sub(last).Seconds()
+		sumX += x
+		sumY += s.Value
+		sumXY += x * s.Value
+		sumXX += x * x
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return history[len(history)-1].Value
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - slope*sumX) / n
+	return intercept + slope*horizon.Seconds()
+}
+
+// holtForecast Holt 双指数平滑,把样本视为等间隔,按平均间隔把 horizon 换算为步数
+func holtForecast(history []Sample, f conf.ForecastConfig) float64 {
+	level := history[0].Value
+	trend := history[1].Value - history[0].Value
+	for _, s := range history[1:] {
+		prevLevel := level
+		level = f.Alpha*s.Value + (1-f.Alpha)*(level+trend)
+		trend = f.Beta*(level-prevLevel) + (1-f.Beta)*trend
+	}
+
+	interval := history[len(history)-1].Time.Sub(history[0].Time) / time.Duration(len(history)-1)
+	if interval <= 0 {
+		return level
+	}
+	steps := f.Horizon.Seconds() / interval.Seconds()
+	return level + steps*trend
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"kube-scheduler-extender/conf"
+	"math"
+	"testing"
+	"time"
+)
+
+// samples 按 interval 等间隔生成样本
+func samples(interval time.Duration, values ...float64) []Sample {
+	start := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	history := make([]Sample, 0, len(values))
+	for i, v := range values {
+		history = append(history, Sample{Time: start.Add(time.Duration(i) * interval), Value: v})
+	}
+	return history
+}
+
+func TestForecast(t *testing.T) {
+	linear := conf.ForecastConfig{Method: conf.ForecastLinear, Horizon: time.Minute}
+	holt := conf.ForecastConfig{Method: conf.ForecastHolt, Horizon: time.Minute, Alpha: 0.5, Beta: 0.5}
+
+	tests := []struct {
+		name    string
+		config  conf.ForecastConfig
+		history []Sample
+		want    float64
+		wantOk  bool
+	}{
+		{name: "样本不足", config: linear, history: samples(time.Minute, 10)},
+		{name: "线性增长外推", config: linear, history: samples(time.Minute, 10, 20, 30), want: 40, wantOk: true},
+		{name: "线性下降外推两个间隔", config: conf.ForecastConfig{Method: conf.ForecastLinear, Horizon: 2 * time.Minute}, history: samples(time.Minute, 30, 20, 10), want: -10, wantOk: true},
+		{name: "线性回归拟合噪声", config: linear, history: samples(time.Minute, 10, 30, 20, 40), want: 45, wantOk: true},
+		{name: "样本时间相同使用最新值", config: linear, history: samples(0, 10, 20), want: 20, wantOk: true},
+		{name: "holt 平稳", config: holt, history: samples(time.Minute, 50, 50, 50, 50), want: 50, wantOk: true},
+		{name: "holt 线性增长外推", config: holt, history: samples(time.Minute, 10, 20, 30), want: 40, wantOk: true},
+		{name: "holt 按平均间隔换算步数", config: holt, history: samples(30*time.Second, 10, 20, 30), want: 50, wantOk: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &NodeMetric{History: tt.history}
+			got, ok := m.Forecast(tt.config)
+			if ok != tt.wantOk {
+				t.Fatalf("Forecast() ok = %v, 期望 %v", ok, tt.wantOk)
+			}
+			if ok && math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Forecast() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	history := samples(time.Minute, 1, 2, 3)
+	got := appendHistory(history, Sample{Value: 4}, 3)
+	if len(got) != 3 || got[0].Value != 2 || got[2].Value != 4 {
+		t.Fatalf("appendHistory() = %v, 期望保留最近 3 个样本 2, 3, 4", got)
+	}
+	// 不修改旧的历史
+	got[0].Value = 100
+	if history[1].Value != 2 {
+		t.Errorf("appendHistory() 与旧的历史共享底层数组")
+	}
+}
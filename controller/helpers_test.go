@@ -0,0 +1,73 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"kube-scheduler-extender/conf"
+	"testing"
+)
+
+// setTestConfig 使用 c 作为当前配置,测试结束后恢复
+func setTestConfig(t *testing.T, c *conf.Config) {
+	old := conf.Get()
+	conf.Store(c)
+	t.Cleanup(func() {
+		if old != nil {
+			conf.Store(old)
+		}
+	})
+}
+
+// setTestNodeLister 用 nodes 代替 node informer 缓存,测试结束后恢复
+func setTestNodeLister(t *testing.T, nodes ...*v1.Node) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{nodeAddressIndex: nodeAddressIndexFunc})
+	for _, node := range nodes {
+		if err := indexer.Add(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldLister, oldIndexer := nodeLister, nodeIndexer
+	nodeLister, nodeIndexer = corelisters.NewNodeLister(indexer), indexer
+	t.Cleanup(func() { nodeLister, nodeIndexer = oldLister, oldIndexer })
+}
+
+// setTestPodLister 用 pods 代替 pod informer 缓存,测试结束后恢复
+func setTestPodLister(t *testing.T, pods ...*v1.Pod) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{podUIDIndex: podUIDIndexFunc})
+	for _, pod := range pods {
+		if err := indexer.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldLister, oldIndexer := podLister, podIndexer
+	podLister, podIndexer = corelisters.NewPodLister(indexer), indexer
+	t.Cleanup(func() { podLister, podIndexer = oldLister, oldIndexer })
+}
+
+func newTestNode(name string, allocatable v1.ResourceList) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     v1.NodeStatus{Allocatable: allocatable},
+	}
+}
+
+func newTestPod(namespace, name, uid, memory, cpu string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID(uid)},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name: "app",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceMemory: resource.MustParse(memory),
+						v1.ResourceCPU:    resource.MustParse(cpu),
+					},
+				},
+			}},
+		},
+	}
+}
@@ -0,0 +1,327 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
+	"kube-scheduler-extender/conf"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// KubeletDataSource 从每个 node 的 kubelet /stats/summary 查询
+	KubeletDataSource = conf.KubeletDataSource
+
+	// KubeletAccessProxy 通过 API server 的 node proxy 访问 kubelet
+	KubeletAccessProxy = conf.KubeletAccessProxy
+	// KubeletAccessDirect 直接访问 node InternalIP 上的 kubelet 端口
+	KubeletAccessDirect = conf.KubeletAccessDirect
+
+	// kubeletMemoryCapacity snapshot 中保存节点内存容量的 key
+	kubeletMemoryCapacity = "memory_capacity"
+)
+
+// kubeletSummary 对应 kubelet stats/v1alpha1 Summary,只保留需要的字段
+type kubeletSummary struct {
+	Node struct {
+		NodeName string `json:"nodeName"`
+		CPU      *struct {
+			UsageNanoCores *uint64 `json:"usageNanoCores"`
+		} `json:"cpu"`
+		Memory *struct {
+			AvailableBytes  *uint64 `json:"availableBytes"`
+			WorkingSetBytes *uint64 `json:"workingSetBytes"`
+		} `json:"memory"`
+		Fs *struct {
+			CapacityBytes *uint64 `json:"capacityBytes"`
+			UsedBytes     *uint64 `json:"usedBytes"`
+		} `json:"fs"`
+		Rlimit *struct {
+			MaxPID                *int64 `json:"maxpid"`
+			NumOfRunningProcesses *int64 `json:"curproc"`
+		} `json:"rlimit"`
+	} `json:"node"`
+}
+
+// KubeletOptions kubelet 数据源配置
+type KubeletOptions struct {
+	// Access proxy 或 direct
+	Access string
+	// Port direct 模式下 kubelet 端口
+	Port int
+	// InsecureSkipTLSVerify direct 模式下不校验 kubelet 证书
+	InsecureSkipTLSVerify bool
+	// Workers 并发采集的 node 数
+	Workers int
+	// Timeout 单个 node 的采集超时时间
+	Timeout time.Duration
+}
+
+type kubeletSource struct {
+	client  kubernetes.Interface
+	options KubeletOptions
+	// direct 模式使用的 http client,带有 service account 凭证
+	httpClient *http.Client
+
+	lock       sync.Mutex
+	scrapeTime time.Time
+	snapshot   map[string]map[string]float64
+}
+
+// NewKubeletSource 返回 kubelet Summary API 数据源,支持 memory、cpu、filesystem、pid 指标,
+// 指标值均为使用百分比
+func NewKubeletSource(client kubernetes.Interface, config *rest.Config, options KubeletOptions) (DataSource, error) {
+	if options.Workers < 1 {
+		return nil, fmt.Errorf("kubelet_workers 不能小于 1, 当前为 %v", options.Workers)
+	}
+
+	s := &kubeletSource{
+		client:  client,
+		options: options,
+	}
+
+	if options.Access == KubeletAccessDirect {
+		c := rest.CopyConfig(config)
+		c.TLSClientConfig = rest.TLSClientConfig{
+			Insecure: options.InsecureSkipTLSVerify,
+			CAFile:   config.TLSClientConfig.CAFile,
+			CAData:   config.TLSClientConfig.CAData,
+			CertFile: config.TLSClientConfig.CertFile,
+			CertData: config.TLSClientConfig.CertData,
+			KeyFile:  config.TLSClientConfig.KeyFile,
+			KeyData:  config.TLSClientConfig.KeyData,
+		}
+		if options.InsecureSkipTLSVerify {
+			c.TLSClientConfig.CAFile = ""
+			c.TLSClientConfig.CAData = nil
+		}
+		transport, err := rest.TransportFor(c)
+		if err != nil {
+			return nil, err
+		}
+		s.httpClient = &http.Client{Transport: transport, Timeout: options.Timeout}
+	}
+
+	return s, nil
+}
+
+func (s *kubeletSource) Name() string {
+	return KubeletDataSource
+}
+
+func (s *kubeletSource) Supports(m conf.MetricConfig) bool {
+	switch m.Name {
+	case conf.MemoryMetricName, conf.CPUMetricName, conf.FilesystemMetricName, conf.PIDMetricName:
+		return true
+	}
+	return false
+}
+
+func (s *kubeletSource) Fetch(m conf.MetricConfig) (map[string]float64, error) {
+	snapshot, err := s.scrape()
+	if err != nil {
+		return nil, err
+	}
+	return snapshot[m.Name], nil
+}
+
+// FetchCapacity 返回 summary 中的节点内存容量(working set + available,byte)
+func (s *kubeletSource) FetchCapacity(m conf.MetricConfig) (map[string]float64, error) {
+	if m.Name != conf.MemoryMetricName {
+		return nil, fmt.Errorf("kubelet 不支持查询指标 %v 的容量", m.Name)
+	}
+	snapshot, err := s.scrape()
+	if err != nil {
+		return nil, err
+	}
+	return snapshot[kubeletMemoryCapacity], nil
+}
+
+// kubeletSummaryTTL 一轮采集结果的有效期,各指标的定时任务在有效期内复用同一轮结果,
+// 取查询间隔的一半,下一次定时查询时总是重新采集
+func kubeletSummaryTTL() time.Duration {
+	return conf.Get().Intervals.Refresh / 2
+}
+
+// scrape 并发采集所有 node 的 summary,kubeletSummaryTTL 内直接返回上一轮结果
+func (s *kubeletSource) scrape() (map[string]map[string]float64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if time.Since(s.scrapeTime) < kubeletSummaryTTL() {
+		return s.snapshot, nil
+	}
+
+	nodes, err := s.listNodes()
+	if err != nil {
+		log.Errorln("查询 node 列表出错: ", err.Error())
+		return nil, err
+	}
+
+	snapshot := map[string]map[string]float64{
+		conf.MemoryMetricName:     {},
+		conf.CPUMetricName:        {},
+		conf.FilesystemMetricName: {},
+		conf.PIDMetricName:        {},
+		kubeletMemoryCapacity:     {},
+	}
+	var (
+		mu     sync.Mutex
+		failed int
+	)
+
+	workqueue.ParallelizeUntil(context.Background(), s.options.Workers, len(nodes), func(i int) {
+		node := nodes[i]
+		summary, err := s.getSummary(node)
+		if err != nil {
+			log.Errorf("采集 node %v kubelet summary 出错: %v", node.Name, err)
+			mu.Lock()
+			failed++
+			mu.Unlock()
+			return
+		}
+
+		values := summaryValues(node, summary)
+		mu.Lock()
+		for metric, value := range values {
+			snapshot[metric][node.Name] = value
+		}
+		mu.Unlock()
+	})
+
+	if len(nodes) > 0 && failed == len(nodes) {
+		return nil, fmt.Errorf("所有 %v 个 node 的 kubelet summary 采集失败", failed)
+	}
+
+	log.Debugf("kubelet summary 采集完成, node 数量: %v, 失败: %v", len(nodes), failed)
+	s.scrapeTime = time.Now()
+	s.snapshot = snapshot
+	return snapshot, nil
+}
+
+func (s *kubeletSource) listNodes() ([]*v1.Node, error) {
+	if nodeLister != nil {
+		return nodeLister.List(labels.Everything())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.options.Timeout)
+	defer cancel()
+	list, err := s.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*v1.Node, 0, len(list.Items))
+	for i := range list.Items {
+		nodes = append(nodes, &list.Items[i])
+	}
+	return nodes, nil
+}
+
+func (s *kubeletSource) getSummary(node *v1.Node) (*kubeletSummary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.options.Timeout)
+	defer cancel()
+
+	var (
+		body []byte
+		err  error
+	)
+	if s.options.Access == KubeletAccessDirect {
+		body, err = s.getSummaryDirect(ctx, node)
+	} else {
+		body, err = s.client.CoreV1().RESTClient().Get().
+			Resource("nodes").Name(node.Name).SubResource("proxy").Suffix("stats/summary").
+			DoRaw(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var summary kubeletSummary
+	if err := json.Unmarshal(body, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+func (s *kubeletSource) getSummaryDirect(ctx context.Context, node *v1.Node) ([]byte, error) {
+	address := nodeAddress(node)
+	if address == "" {
+		return nil, fmt.Errorf("node %v 没有 InternalIP/Hostname", node.Name)
+	}
+
+	url := "https://" + net.JoinHostPort(address, strconv.Itoa(s.options.Port)) + "/stats/summary"
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("访问出错，错误码为 %v", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// nodeAddress 优先返回 InternalIP,其次 Hostname
+func nodeAddress(node *v1.Node) string {
+	var hostname string
+	for _, address := range node.Status.Addresses {
+		switch address.Type {
+		case v1.NodeInternalIP:
+			return address.Address
+		case v1.NodeHostName:
+			hostname = address.Address
+		}
+	}
+	return hostname
+}
+
+// summaryValues 把 summary 转换为各指标的使用百分比
+func summaryValues(node *v1.Node, summary *kubeletSummary) map[string]float64 {
+	values := make(map[string]float64, 5)
+
+	if m := summary.Node.Memory; m != nil && m.WorkingSetBytes != nil && m.AvailableBytes != nil {
+		if total := *m.WorkingSetBytes + *m.AvailableBytes; total > 0 {
+			values[conf.MemoryMetricName] = float64(*m.WorkingSetBytes) / float64(total) * 100
+			values[kubeletMemoryCapacity] = float64(total)
+		}
+	}
+
+	if c := summary.Node.CPU; c != nil && c.UsageNanoCores != nil {
+		capacity := node.Status.Capacity[v1.ResourceCPU]
+		if capacity.MilliValue() > 0 {
+			values[conf.CPUMetricName] = float64(*c.UsageNanoCores) / float64(capacity.MilliValue()*1e6) * 100
+		}
+	}
+
+	if fs := summary.Node.Fs; fs != nil && fs.UsedBytes != nil && fs.CapacityBytes != nil && *fs.CapacityBytes > 0 {
+		values[conf.FilesystemMetricName] = float64(*fs.UsedBytes) / float64(*fs.CapacityBytes) * 100
+	}
+
+	if r := summary.Node.Rlimit; r != nil && r.NumOfRunningProcesses != nil && r.MaxPID != nil && *r.MaxPID > 0 {
+		values[conf.PIDMetricName] = float64(*r.NumOfRunningProcesses) / float64(*r.MaxPID) * 100
+	}
+
+	return values
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"encoding/json"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"kube-scheduler-extender/conf"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSummaryValues(t *testing.T) {
+	node := &v1.Node{Status: v1.NodeStatus{Capacity: v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")}}}
+
+	tests := []struct {
+		name    string
+		node    *v1.Node
+		summary string
+		want    map[string]float64
+	}{
+		{
+			name: "所有指标",
+			node: node,
+			summary: `{"node": {
+				"cpu": {"usageNanoCores": 1000000000},
+				"memory": {"availableBytes": 3072, "workingSetBytes": 1024},
+				"fs": {"capacityBytes": 100, "usedBytes": 30},
+				"rlimit": {"maxpid": 1000, "curproc": 100}
+			}}`,
+			want: map[string]float64{
+				conf.CPUMetricName:        25,
+				conf.MemoryMetricName:     25,
+				conf.FilesystemMetricName: 30,
+				conf.PIDMetricName:        10,
+				kubeletMemoryCapacity:     4096,
+			},
+		},
+		{name: "没有任何数据", node: node, summary: `{"node": {}}`, want: map[string]float64{}},
+		{
+			name:    "缺少字段",
+			node:    node,
+			summary: `{"node": {"cpu": {}, "memory": {"workingSetBytes": 1024}, "fs": {"usedBytes": 30}, "rlimit": {"curproc": 100}}}`,
+			want:    map[string]float64{},
+		},
+		{
+			name:    "容量为 0",
+			node:    node,
+			summary: `{"node": {"memory": {"availableBytes": 0, "workingSetBytes": 0}, "fs": {"capacityBytes": 0, "usedBytes": 0}, "rlimit": {"maxpid": 0, "curproc": 0}}}`,
+			want:    map[string]float64{},
+		},
+		{
+			name:    "node 没有 cpu capacity",
+			node:    &v1.Node{},
+			summary: `{"node": {"cpu": {"usageNanoCores": 1000000000}}}`,
+			want:    map[string]float64{},
+		},
+		{
+			name:    "cpu 按 capacity 换算",
+			node:    &v1.Node{Status: v1.NodeStatus{Capacity: v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")}}},
+			summary: `{"node": {"cpu": {"usageNanoCores": 250000000}}}`,
+			want:    map[string]float64{conf.CPUMetricName: 50},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var summary kubeletSummary
+			if err := json.Unmarshal([]byte(tt.summary), &summary); err != nil {
+				t.Fatal(err)
+			}
+			got := summaryValues(tt.node, &summary)
+			if len(got) != len(tt.want) {
+				t.Fatalf("summaryValues() = %v, 期望 %v", got, tt.want)
+			}
+			for metric, want := range tt.want {
+				if value, exist := got[metric]; !exist || math.Abs(value-want) > 1e-9 {
+					t.Errorf("%v = %v, 期望 %v", metric, value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestKubeletScrape(t *testing.T) {
+	// 有效期为查询间隔的一半
+	setTestConfig(t, &conf.Config{Intervals: conf.IntervalsConfig{Refresh: time.Minute}})
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"node": {"memory": {"availableBytes": 3072, "workingSetBytes": 1024}}}`))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, portStr, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	// node-1 可以访问 kubelet, 没有地址的 node 采集失败
+	reachable := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status:     v1.NodeStatus{Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: host}}},
+	}
+	unreachable := func(name string) *v1.Node {
+		return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+
+	tests := []struct {
+		name    string
+		nodes   []*v1.Node
+		want    map[string]float64
+		wantErr bool
+	}{
+		{name: "部分 node 失败时返回成功的 node", nodes: []*v1.Node{reachable, unreachable("node-2")}, want: map[string]float64{"node-1": 25}},
+		{name: "所有 node 失败", nodes: []*v1.Node{unreachable("node-2"), unreachable("node-3")}, wantErr: true},
+		{name: "没有 node", want: map[string]float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			for _, node := range tt.nodes {
+				client.Tracker().Add(node)
+			}
+			s := &kubeletSource{
+				client:     client,
+				options:    KubeletOptions{Access: KubeletAccessDirect, Port: port, Workers: 2, Timeout: 5 * time.Second},
+				httpClient: server.Client(),
+			}
+
+			got, err := s.Fetch(conf.MetricConfig{Name: conf.MemoryMetricName})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Fetch() = %v, 期望 %v", got, tt.want)
+			}
+			for nodeName, want := range tt.want {
+				if math.Abs(got[nodeName]-want) > 1e-9 {
+					t.Errorf("node %v 指标值 %v, 期望 %v", nodeName, got[nodeName], want)
+				}
+			}
+
+			// 有效期内其它指标复用同一轮采集结果
+			before := atomic.LoadInt32(&requests)
+			if _, err := s.Fetch(conf.MetricConfig{Name: conf.CPUMetricName}); err != nil {
+				t.Fatal(err)
+			}
+			if after := atomic.LoadInt32(&requests); after != before {
+				t.Errorf("有效期内重新采集了 %v 次", after-before)
+			}
+		})
+	}
+}
+
+func TestNewKubeletSourceWorkers(t *testing.T) {
+	if _, err := NewKubeletSource(fake.NewSimpleClientset(), nil, KubeletOptions{Access: KubeletAccessDirect, Workers: 0}); err == nil {
+		t.Errorf("kubelet_workers 为 0 时期望返回错误")
+	}
+}
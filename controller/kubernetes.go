@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"errors"
+	"github.com/prometheus/common/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"net"
+)
+
+const (
+	// nodeAddressIndex node informer 按 InternalIP/Hostname 建立的索引
+	nodeAddressIndex = "address"
+	// podUIDIndex pod informer 按 uid 建立的索引
+	podUIDIndex = "uid"
+)
+
+var (
+	KubeConfig        *rest.Config
+	KubeClient        kubernetes.Interface
+	KubeDynamicClient dynamic.Interface
+
+	nodeLister  corelisters.NodeLister
+	nodeIndexer cache.Indexer
+	// podLister 开启预留或抢占时由 StartPodInformer 设置
+	podLister  corelisters.PodLister
+	podIndexer cache.Indexer
+)
+
+// NewKubeClient 创建 k8s client, kubeconfig 为空时使用 in-cluster 配置
+func NewKubeClient(kubeconfig string) error {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	KubeConfig = config
+	KubeClient = client
+	KubeDynamicClient = dynamicClient
+	return nil
+}
+
+// StartNodeInformer 启动 node informer,并等待缓存同步完成
+func StartNodeInformer(client kubernetes.Interface, stopCh <-chan struct{}) error {
+	factory := informers.NewSharedInformerFactory(client, 0)
+	informer := factory.Core().V1().Nodes()
+	if err := informer.Informer().AddIndexers(cache.Indexers{nodeAddressIndex: nodeAddressIndexFunc}); err != nil {
+		return err
+	}
+
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.Informer().HasSynced) {
+		return errors.New("等待 node informer 同步超时")
+	}
+
+	nodeLister = informer.Lister()
+	nodeIndexer = informer.Informer().GetIndexer()
+	log.Infoln("node informer 同步完成")
+	return nil
+}
+
+func nodeAddressIndexFunc(obj interface{}) ([]string, error) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return nil, nil
+	}
+
+	var addresses []string
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP || address.Type == v1.NodeHostName {
+			addresses = append(addresses, address.Address)
+		}
+	}
+	return addresses, nil
+}
+
+// lookupNodeName 按 node 名、InternalIP 或 Hostname 查找 node,name 可以带端口
+func lookupNodeName(name string) (string, bool) {
+	if nodeLister == nil {
+		return "", false
+	}
+
+	candidates := []string{name}
+	if host, _, err := net.SplitHostPort(name); err == nil {
+		candidates = append(candidates, host)
+	}
+
+	for _, candidate := range candidates {
+		if _, err := nodeLister.Get(candidate); err == nil {
+			return candidate, true
+		}
+		objs, err := nodeIndexer.ByIndex(nodeAddressIndex, candidate)
+		if err == nil && len(objs) > 0 {
+			return objs[0].(*v1.Node).Name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/common/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"kube-scheduler-extender/conf"
+	"time"
+)
+
+// NodeMetricsResource metrics-server 提供的 NodeMetrics 资源
+var NodeMetricsResource = schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "nodes"}
+
+// nodeMetrics 对应 metrics.k8s.io/v1beta1 NodeMetrics,只保留需要的字段
+type nodeMetrics struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Timestamp metav1.Time     `json:"timestamp"`
+	Window    metav1.Duration `json:"window"`
+	Usage     v1.ResourceList `json:"usage"`
+}
+
+type metricsServerSource struct {
+	client  kubernetes.Interface
+	dynamic dynamic.Interface
+	timeout time.Duration
+}
+
+// NewMetricsServerSource 返回 metrics-server 数据源,只支持内置的 memory 和 cpu 指标,
+// 指标值为 NodeMetrics 中的使用量占 node allocatable 的百分比
+func NewMetricsServerSource(client kubernetes.Interface, dynamicClient dynamic.Interface, timeout time.Duration) DataSource {
+	return &metricsServerSource{
+		client:  client,
+		dynamic: dynamicClient,
+		timeout: timeout,
+	}
+}
+
+func (s *metricsServerSource) Name() string {
+	return MetricsServerDataSource
+}
+
+func (s *metricsServerSource) Supports(m conf.MetricConfig) bool {
+	return m.Name == conf.MemoryMetricName || m.Name == conf.CPUMetricName
+}
+
+func (s *metricsServerSource) Fetch(m conf.MetricConfig) (map[string]float64, error) {
+	samples, err := s.FetchSamples(m)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]float64, len(samples))
+	for nodeName, sample := range samples {
+		values[nodeName] = sample.Value
+	}
+	return values, nil
+}
+
+// FetchSamples 样本时间为 NodeMetrics 的 timestamp,即采集窗口的结束时间
+func (s *metricsServerSource) FetchSamples(m conf.MetricConfig) (map[string]Sample, error) {
+	var resourceName v1.ResourceName
+	switch m.Name {
+	case conf.MemoryMetricName:
+		resourceName = v1.ResourceMemory
+	case conf.CPUMetricName:
+		resourceName = v1.ResourceCPU
+	default:
+		return nil, fmt.Errorf("metrics-server 不支持指标 %v", m.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	allocatable, err := s.nodeAllocatable(ctx, resourceName)
+	if err != nil {
+		log.Errorln("查询 node allocatable 出错: ", err.Error())
+		return nil, err
+	}
+
+	list, err := s.dynamic.Resource(NodeMetricsResource).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorln("查询 metrics.k8s.io NodeMetrics 出错: ", err.Error())
+		return nil, err
+	}
+
+	samples := make(map[string]Sample, len(list.Items))
+	for _, item := range list.Items {
+		var metrics nodeMetrics
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.UnstructuredContent(), &metrics); err != nil {
+			log.Errorln("NodeMetrics 转换错误: ", err.Error())
+			continue
+		}
+
+		total, exist := allocatable[metrics.Name]
+		if !exist || total.IsZero() {
+			continue
+		}
+		usage := metrics.Usage[resourceName]
+		samples[metrics.Name] = Sample{Time: metrics.Timestamp.Time, Value: percent(usage, total, resourceName)}
+	}
+
+	return samples, nil
+}
+
+// FetchCapacity 返回 node allocatable 内存(byte),与 Fetch 计算百分比时使用的分母相同
+func (s *metricsServerSource) FetchCapacity(m conf.MetricConfig) (map[string]float64, error) {
+	if m.Name != conf.MemoryMetricName {
+		return nil, fmt.Errorf("metrics-server 不支持查询指标 %v 的容量", m.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	allocatable, err := s.nodeAllocatable(ctx, v1.ResourceMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity := make(map[string]float64, len(allocatable))
+	for nodeName, total := range allocatable {
+		capacity[nodeName] = float64(total.Value())
+	}
+	return capacity, nil
+}
+
+// nodeAllocatable 返回 node 名 -> allocatable,优先使用 node informer 缓存
+func (s *metricsServerSource) nodeAllocatable(ctx context.Context, resourceName v1.ResourceName) (map[string]resource.Quantity, error) {
+	var nodes []*v1.Node
+	if nodeLister != nil {
+		list, err := nodeLister.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		nodes = list
+	} else {
+		list, err := s.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			nodes = append(nodes, &list.Items[i])
+		}
+	}
+
+	allocatable := make(map[string]resource.Quantity, len(nodes))
+	for _, node := range nodes {
+		allocatable[node.Name] = node.Status.Allocatable[resourceName]
+	}
+	return allocatable, nil
+}
+
+func percent(usage, total resource.Quantity, resourceName v1.ResourceName) float64 {
+	if resourceName == v1.ResourceCPU {
+		return float64(usage.MilliValue()) / float64(total.MilliValue()) * 100
+	}
+	return float64(usage.Value()) / float64(total.Value()) * 100
+}
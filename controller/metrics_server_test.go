@@ -0,0 +1,105 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+	"kube-scheduler-extender/conf"
+	"math"
+	"testing"
+	"time"
+)
+
+func newTestNodeMetrics(name, cpu, memory string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "metrics.k8s.io/v1beta1",
+		"kind":       "NodeMetrics",
+		"metadata":   map[string]interface{}{"name": name},
+		"timestamp":  "2020-10-01T00:00:00Z",
+		"window":     "30s",
+		"usage":      map[string]interface{}{"cpu": cpu, "memory": memory},
+	}
+}
+
+// newTestDynamicClient 返回 list metrics.k8s.io nodes 时得到 items 的 dynamic client,
+// fake 的 object tracker 按 kind 猜测的 resource 与 NodeMetrics 的 nodes 不一致,这里直接用 reactor 返回
+func newTestDynamicClient(items ...map[string]interface{}) *dynamicfake.FakeDynamicClient {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	client.PrependReactor("list", NodeMetricsResource.Resource, func(action core.Action) (bool, runtime.Object, error) {
+		if action.GetResource() != NodeMetricsResource {
+			return false, nil, nil
+		}
+		list := &unstructured.UnstructuredList{Object: map[string]interface{}{
+			"apiVersion": "metrics.k8s.io/v1beta1",
+			"kind":       "NodeMetricsList",
+		}}
+		for _, item := range items {
+			list.Items = append(list.Items, unstructured.Unstructured{Object: item})
+		}
+		return true, list, nil
+	})
+	return client
+}
+
+func TestMetricsServerFetch(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		newTestNode("node-1", v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("4"),
+			v1.ResourceMemory: resource.MustParse("8Gi"),
+		}),
+		newTestNode("node-2", v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("2"),
+			v1.ResourceMemory: resource.MustParse("4Gi"),
+		}),
+		// 没有 allocatable 的 node 不能换算百分比
+		newTestNode("node-3", nil),
+	)
+	source := NewMetricsServerSource(client, newTestDynamicClient(
+		newTestNodeMetrics("node-1", "1", "2Gi"),
+		newTestNodeMetrics("node-2", "1500m", "3Gi"),
+		newTestNodeMetrics("node-3", "500m", "1Gi"),
+		// 不在 node 列表中的 NodeMetrics 忽略
+		newTestNodeMetrics("node-4", "500m", "1Gi"),
+	), time.Second)
+
+	tests := []struct {
+		metric string
+		want   map[string]float64
+	}{
+		{metric: conf.CPUMetricName, want: map[string]float64{"node-1": 25, "node-2": 75}},
+		{metric: conf.MemoryMetricName, want: map[string]float64{"node-1": 25, "node-2": 75}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			got, err := source.Fetch(conf.MetricConfig{Name: tt.metric})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Fetch() = %v, 期望 %v", got, tt.want)
+			}
+			for nodeName, want := range tt.want {
+				if value, exist := got[nodeName]; !exist || math.Abs(value-want) > 1e-9 {
+					t.Errorf("node %v 指标值 %v, 期望 %v", nodeName, value, want)
+				}
+			}
+		})
+	}
+
+	samples, err := source.(SampleSource).FetchSamples(conf.MetricConfig{Name: conf.MemoryMetricName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC); !samples["node-1"].Time.Equal(want) {
+		t.Errorf("样本时间 %v, 期望 NodeMetrics timestamp %v", samples["node-1"].Time, want)
+	}
+
+	if _, err := source.Fetch(conf.MetricConfig{Name: "load", Query: "load"}); err == nil {
+		t.Errorf("Fetch(load) 期望返回错误")
+	}
+}
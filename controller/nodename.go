@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"github.com/prometheus/common/log"
+	"kube-scheduler-extender/conf"
+)
+
+// resolveNodeName 把 prometheus 结果中的 label 值转换为 k8s node 名,
+// 先按 node_name_regex 改写,开启 node_lookup 时再通过 node informer 查找
+func resolveNodeName(raw string) string {
+	c := conf.Get().NodeName
+
+	name := raw
+	if c.Regex != nil && c.Regex.MatchString(raw) {
+		name = c.Regex.ReplaceAllString(raw, c.Replacement)
+	}
+
+	if c.Lookup {
+		if nodeName, ok := lookupNodeName(name); ok {
+			return nodeName
+		}
+		log.Debugf("label 值 %v 改写为 %v 后,未找到对应的 node", raw, name)
+	}
+
+	return name
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kube-scheduler-extender/conf"
+	"regexp"
+	"testing"
+)
+
+func TestResolveNodeName(t *testing.T) {
+	setTestNodeLister(t, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+			{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+			{Type: v1.NodeHostName, Address: "host-1"},
+			{Type: v1.NodeExternalIP, Address: "1.2.3.4"},
+		}},
+	})
+
+	tests := []struct {
+		name        string
+		regex       *regexp.Regexp
+		replacement string
+		lookup      bool
+		raw         string
+		want        string
+	}{
+		{name: "不改写", raw: "10.0.0.1:9100", want: "10.0.0.1:9100"},
+		{name: "正则去掉端口", regex: regexp.MustCompile(`^(.+):\d+$`), replacement: "$1", raw: "node-1:9100", want: "node-1"},
+		{name: "按 node 名查找", lookup: true, raw: "node-1", want: "node-1"},
+		{name: "按 InternalIP 查找", lookup: true, raw: "10.0.0.1", want: "node-1"},
+		{name: "InternalIP 带端口", lookup: true, raw: "10.0.0.1:9100", want: "node-1"},
+		{name: "按 Hostname 查找", lookup: true, raw: "host-1:9100", want: "node-1"},
+		{name: "ExternalIP 不参与查找", lookup: true, raw: "1.2.3.4", want: "1.2.3.4"},
+		{name: "改写后再查找", regex: regexp.MustCompile(`^ip-(\d+)-(\d+)-(\d+)-(\d+)$`), replacement: "$1.$2.$3.$4", lookup: true, raw: "ip-10-0-0-1", want: "node-1"},
+		{name: "查找不到时使用改写后的值", regex: regexp.MustCompile(`^(.+):\d+$`), replacement: "$1", lookup: true, raw: "10.0.0.9:9100", want: "10.0.0.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, &conf.Config{NodeName: conf.NodeNameConfig{
+				Label:       "instance",
+				Regex:       tt.regex,
+				Replacement: tt.replacement,
+				Lookup:      tt.lookup,
+			}})
+			if got := resolveNodeName(tt.raw); got != tt.want {
+				t.Errorf("resolveNodeName(%q) = %q, 期望 %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+
+	nodeLister = nil
+	if _, ok := lookupNodeName("10.0.0.1"); ok {
+		t.Errorf("没有 node informer 时 lookupNodeName() 期望找不到")
+	}
+}
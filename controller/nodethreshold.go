@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"github.com/prometheus/common/log"
+	v1 "k8s.io/api/core/v1"
+	"kube-scheduler-extender/conf"
+)
+
+// MetricForNode 返回按 node label 规则和 annotation 覆盖阈值后的指标配置,
+// node 为空(nodeCacheCapable: true 时请求中没有 node 详情)时从 node informer 缓存中查找
+func MetricForNode(m conf.MetricConfig, node *v1.Node, nodeName string) conf.MetricConfig {
+	if !conf.Get().NodeThresholdsEnabled() {
+		return m
+	}
+
+	if node == nil || node.Name == "" {
+		if nodeLister == nil {
+			return m
+		}
+		cached, err := nodeLister.Get(nodeName)
+		if err != nil {
+			return m
+		}
+		node = cached
+	}
+
+	result, err := m.ForNode(node.Labels, node.Annotations)
+	if err != nil {
+		log.Warnf("node %v 的 %v 阈值 annotation 格式错误: %v", nodeName, m.Name, err)
+		return m
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"kube-scheduler-extender/conf"
+	"testing"
+)
+
+func TestMetricForNode(t *testing.T) {
+	setTestConfig(t, &conf.Config{NodeThresholdAnnotations: true})
+
+	annotated := newTestNode("node-1", nil)
+	annotated.Annotations = map[string]string{conf.ThresholdAnnotation("load"): "50"}
+	malformed := newTestNode("node-2", nil)
+	malformed.Annotations = map[string]string{conf.ThresholdAnnotation("load"): "high"}
+	setTestNodeLister(t, annotated, malformed)
+
+	m := conf.MetricConfig{Name: "load", Threshold: 80}
+
+	tests := []struct {
+		name     string
+		node     *v1.Node
+		nodeName string
+		want     float64
+	}{
+		{name: "请求中的 node", node: annotated, nodeName: "node-1", want: 50},
+		{name: "请求中没有 node 时从缓存查找", nodeName: "node-1", want: 50},
+		{name: "请求中 node 为空对象时从缓存查找", node: &v1.Node{}, nodeName: "node-1", want: 50},
+		{name: "缓存中没有 node", nodeName: "node-3", want: 80},
+		{name: "annotation 格式错误时使用指标配置", node: malformed, nodeName: "node-2", want: 80},
+		{name: "缓存中 annotation 格式错误", nodeName: "node-2", want: 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MetricForNode(m, tt.node, tt.nodeName); got.Threshold != tt.want {
+				t.Errorf("MetricForNode() 阈值 = %v, 期望 %v", got.Threshold, tt.want)
+			}
+		})
+	}
+
+	t.Run("没有 node informer", func(t *testing.T) {
+		setTestNodeLister(t)
+		nodeLister = nil
+		if got := MetricForNode(m, nil, "node-1"); got.Threshold != 80 {
+			t.Errorf("MetricForNode() 阈值 = %v, 期望 80", got.Threshold)
+		}
+	})
+
+	t.Run("未开启 node 阈值", func(t *testing.T) {
+		setTestConfig(t, &conf.Config{})
+		if got := MetricForNode(m, annotated, "node-1"); got.Threshold != 80 {
+			t.Errorf("MetricForNode() 阈值 = %v, 期望 80", got.Threshold)
+		}
+	})
+}
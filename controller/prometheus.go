@@ -1,13 +1,14 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/prometheus/common/log"
 	"io/ioutil"
-	"k8s.io/apimachinery/pkg/util/wait"
 	"kube-scheduler-extender/conf"
 	"kube-scheduler-extender/metrics"
-	"kube-scheduler-extender/util"
+	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -15,120 +16,470 @@ import (
 	"time"
 )
 
-const (
-	// 节点数据失效时间
-	NodeOverdueTime = 180 * time.Second
-)
-
 var NodeInfo *Nodes
 
-func NewNodeInfo(stopCh <-chan struct{}) {
+func NewNodeInfo(source DataSource, stopCh <-chan struct{}) {
 	NodeInfo = &Nodes{
-		stop:    stopCh,
-		NodeMem: make(map[string]*NodeMemory),
+		stop:        stopCh,
+		source:      source,
+		NodeMetrics: make(map[string]map[string]*NodeMetric),
+		lastSuccess: make(map[string]time.Time),
+		fetchers:    make(map[string]context.CancelFunc),
+		health:      make(map[string]*fetchHealth),
 	}
 
 	NodeInfo.run()
 }
 
 type Nodes struct {
-	stop <-chan struct{}
-	Lock sync.RWMutex
+	stop   <-chan struct{}
+	source DataSource
+	Lock   sync.RWMutex
+
+	// NodeMetrics 指标名 -> 节点名 -> 指标数据
+	NodeMetrics map[string]map[string]*NodeMetric
+
+	// lastSuccess 指标名 -> 最后一次成功从数据源查询的时间,受 Lock 保护
+	lastSuccess map[string]time.Time
 
-	NodeMem map[string]*NodeMemory
+	// fetchers 指标名 -> 停止该指标定时查询的函数,受 Lock 保护
+	fetchers map[string]context.CancelFunc
+
+	// health 指标名 -> 查询的退避状态,受 Lock 保护
+	health map[string]*fetchHealth
 }
 
-type NodeMemory struct {
+type NodeMetric struct {
 	NodeName string
-	Value    int
-	// 节点过期时间, 如果 currentTime - CheckTime > nodeOverdueTime,说明节点内存恢复正常,从NodeMems.Nodes 删除
+	Value    float64
+	// 节点过期时间, 如果 currentTime - CheckTime > nodeOverdueTime,说明节点负载恢复正常,从 NodeMetrics 删除
 	CheckTime time.Time
+	// SampleTime 数据源中样本的时间,早于 CheckTime. 数据源没有提供时为空
+	SampleTime time.Time
+	// Capacity 节点容量,指标 100% 对应的绝对值(例如内存 byte),为 0 表示未知
+	Capacity float64
+	// Excluded 开启滞后时,节点超过高水位后为 true,回落到低水位以内后为 false
+	Excluded bool
+	// History 最近的样本,按时间排序,只有配置了 forecast 的指标才记录
+	History []Sample
+}
+
+// overdueTime 节点数据失效时间
+func overdueTime() time.Duration {
+	return conf.Get().Intervals.Overdue
+}
+
+// Fresh 判断数据是否在 overdueTime 内
+func (m *NodeMetric) Fresh(now time.Time) bool {
+	return now.Sub(m.CheckTime) <= overdueTime()
+}
+
+// Reserved 返回样本时间减去 SampleLag 之后调度到 node 上的 pod 预留,
+// m 为 nil 或者没有样本时间时返回所有未过期的预留
+func (m *NodeMetric) Reserved(metric, nodeName string, now time.Time) float64 {
+	var since time.Time
+	if m != nil && !m.SampleTime.IsZero() {
+		since = m.SampleTime.Add(-conf.Get().Reservation.SampleLag)
+	}
+	return PodReservations.Load(metric, nodeName, since, now)
+}
+
+// Usable 判断数据能否用于预选: 未过期,或者 keep-last 策略下仍在宽限期内
+func (m *NodeMetric) Usable(now time.Time) bool {
+	if m.Fresh(now) {
+		return true
+	}
+	return conf.Get().Staleness.Policy == conf.StalePolicyKeepLast && now.Sub(m.CheckTime) <= retentionTime()
+}
+
+// retentionTime 节点数据在缓存中保留的时间
+func retentionTime() time.Duration {
+	if conf.Get().Staleness.Policy == conf.StalePolicyKeepLast {
+		return overdueTime() + conf.Get().Staleness.GracePeriod
+	}
+	return overdueTime()
+}
+
+// Degraded 返回超过 overdueTime 没有成功查询的指标,为空说明数据源正常
+func (n *Nodes) Degraded() []string {
+	now := time.Now()
+	var degraded []string
+
+	n.Lock.RLock()
+	defer n.Lock.RUnlock()
+	for _, m := range conf.Get().Metrics {
+		if now.Sub(n.lastSuccess[m.Name]) > overdueTime() {
+			degraded = append(degraded, m.Name)
+		}
+	}
+	return degraded
+}
+
+// Get 读取节点某个指标的缓存数据,调用方需持有读锁
+func (n *Nodes) Get(metric, nodeName string) (*NodeMetric, bool) {
+	v, exist := n.NodeMetrics[metric][nodeName]
+	return v, exist
 }
 
 func (n *Nodes) run() {
-	go wait.Until(n.fromPrometheusGetMemData, 60*time.Second, n.stop)
-	go wait.Until(n.flushOverdueNode, 30*time.Second, n.stop)
+	n.SyncMetrics()
+	go loop(n.stop, func() time.Duration {
+		n.flushOverdueNode()
+		return conf.Get().Intervals.Flush
+	})
 	ListenForSignal(n.stop)
 }
 
+// SyncMetrics 按当前配置启动新增指标的定时查询,停止已删除指标的查询并清理缓存.
+// 每次查询都读取当前配置,热加载修改的 query 等配置在下一次查询时生效
+func (n *Nodes) SyncMetrics() {
+	startTime := time.Now()
+	current := make(map[string]bool)
+
+	n.Lock.Lock()
+	defer n.Lock.Unlock()
+	for _, m := range conf.Get().Metrics {
+		current[m.Name] = true
+		if _, running := n.fetchers[m.Name]; running {
+			continue
+		}
+
+		n.NodeMetrics[m.Name] = make(map[string]*NodeMetric)
+		// 开始查询后 overdueTime 内没有成功查询才认为数据源降级
+		n.lastSuccess[m.Name] = startTime
+		n.health[m.Name] = &fetchHealth{}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		n.fetchers[m.Name] = cancel
+		go func() {
+			select {
+			case <-n.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		go n.poll(ctx, m.Name)
+	}
+
+	for name, cancel := range n.fetchers {
+		if current[name] {
+			continue
+		}
+		log.Infof("指标 %v 已从配置中删除,停止查询并清理缓存", name)
+		cancel()
+		for nodeName := range n.NodeMetrics[name] {
+			metrics.NodeExcluded.DeleteLabelValues(name, nodeName)
+		}
+		delete(n.fetchers, name)
+		delete(n.NodeMetrics, name)
+		delete(n.lastSuccess, name)
+		delete(n.health, name)
+		metrics.CacheSize.DeleteLabelValues(name)
+		metrics.DataSourceDegraded.DeleteLabelValues(name)
+		metrics.DataSourceBackoffState.DeleteLabelValues(name)
+		metrics.DataSourceBackoffSeconds.DeleteLabelValues(name)
+	}
+}
+
+// fetchMetric 按当前配置查询指标,指标已被删除时不查询
+func (n *Nodes) fetchMetric(name string) error {
+	for _, m := range conf.Get().Metrics {
+		if m.Name == name {
+			return n.fetchData(m)
+		}
+	}
+	return nil
+}
+
 func (n *Nodes) flushOverdueNode() {
 	currentTime := time.Now()
+	retention := retentionTime()
+	overdue := overdueTime()
 	n.Lock.Lock()
-	for k, v := range n.NodeMem {
-		if currentTime.Sub(v.CheckTime) >= NodeOverdueTime {
-			log.Infoln("节点 ", k, " 数据过期,从cache中删除,", " memoryValue:"+strconv.Itoa(v.Value)+"; checkTime:"+v.CheckTime.Format("2006-01-02 15:04:05")+";")
-			delete(n.NodeMem, k)
+	// 热加载时 SyncMetrics 会修改 NodeMetrics,持有锁之后再读取
+	sizes := make(map[string]int, len(n.NodeMetrics))
+	degraded := make(map[string]bool, len(n.NodeMetrics))
+	for metric, nodes := range n.NodeMetrics {
+		degraded[metric] = currentTime.Sub(n.lastSuccess[metric]) > overdue
+		for k, v := range nodes {
+			if currentTime.Sub(v.CheckTime) >= retention {
+				log.Infoln("节点 ", k, " ", metric, " 数据过期,从cache中删除,", " value:"+formatValue(v.Value)+"; checkTime:"+v.CheckTime.Format("2006-01-02 15:04:05")+";")
+				delete(nodes, k)
+				metrics.NodeExcluded.DeleteLabelValues(metric, k)
+			}
 		}
+		sizes[metric] = len(nodes)
 	}
 	n.Lock.Unlock()
 
 	// updateMetrics
-	metrics.CacheSize.WithLabelValues().Set(float64(len(n.NodeMem)))
+	for metric, size := range sizes {
+		metrics.CacheSize.WithLabelValues(metric).Set(float64(size))
+	}
+	for metric, d := range degraded {
+		if d {
+			log.Warnf("指标 %v 超过 %v 没有从数据源 %v 成功查询, 数据源降级, 过期策略: %v", metric, overdue, n.source.Name(), conf.Get().Staleness.Policy)
+			metrics.DataSourceDegraded.WithLabelValues(metric).Set(1)
+		} else {
+			metrics.DataSourceDegraded.WithLabelValues(metric).Set(0)
+		}
+	}
 }
 
 type PrometheusResult struct {
 	Data struct {
 		Result []struct {
-			Metric struct {
-				Instance string `json:"instance"`
-			} `json:"metric"`
+			Metric map[string]string `json:"metric"`
+			// Value 即时查询(vector)的样本: [时间戳, "值"]
 			Value []interface{} `json:"value"`
+			// Values range 查询(matrix)的样本列表
+			Values [][]interface{} `json:"values"`
 		} `json:"result"`
 		ResultType string `json:"resultType"`
 	} `json:"data"`
 	Status string `json:"status"`
 }
 
-func (n *Nodes) fromPrometheusGetMemData() {
-	startGetDataEvalTime := time.Now()
-	defer func() {
-		metrics.FromPrometheusGetDataEvaluationDuration.WithLabelValues().Observe(metrics.SinceInSeconds(startGetDataEvalTime))
-	}()
-	urlStr := conf.Conf.PrometheusUrl + "/api/v1/query?query=" + conf.Conf.PrometheusMemoryMetrics
-	urlParse, _ := url.Parse(urlStr)
-	q := urlParse.Query()
+// queryPrometheus 向一个 prometheus endpoint 执行即时查询,返回 node 名 -> 指标值
+func queryPrometheus(client *http.Client, endpoint, query, nodeLabel string) (map[string]float64, error) {
+	q := url.Values{}
+	q.Set("query", query)
+	presult, err := requestPrometheus(client, endpoint, "/api/v1/query", q)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]float64, len(presult.Data.Result))
+	for _, v := range presult.Data.Result {
+		instance := v.Metric[nodeLabel]
+		if instance == "" {
+			continue
+		}
+		_, value, err := parseSample(v.Value)
+		if err != nil {
+			log.Errorln("prometheus 结果转换错误: ", err.Error())
+			continue
+		}
+		values[resolveNodeName(instance)] = value
+	}
+
+	return values, nil
+}
+
+// queryPrometheusRange 向一个 prometheus endpoint 执行 range 查询,每个 series 的样本按 m.Range 聚合,
+// 返回 node 名 -> 聚合后的值,以及 node 名 -> 最新样本的时间戳.
+// 同一个 node 有多个 series(例如 query 没有按 node label 聚合)时取最接近过滤条件的值,不把不同 series 的样本混在一起聚合
+func queryPrometheusRange(client *http.Client, endpoint, nodeLabel string, m conf.MetricConfig) (map[string]float64, map[string]float64, error) {
+	r := *m.Range
+	end := time.Now()
+	q := url.Values{}
+	q.Set("query", m.Query)
+	q.Set("start", strconv.FormatInt(end.Add(-r.Window).Unix(), 10))
+	q.Set("end", strconv.FormatInt(end.Unix(), 10))
+	q.Set("step", strconv.FormatFloat(r.Step.Seconds(), 'f', -1, 64))
+	presult, err := requestPrometheus(client, endpoint, "/api/v1/query_range", q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[string]float64, len(presult.Data.Result))
+	timestamps := make(map[string]float64, len(presult.Data.Result))
+	for _, v := range presult.Data.Result {
+		instance := v.Metric[nodeLabel]
+		if instance == "" {
+			continue
+		}
+		nodeName := resolveNodeName(instance)
+
+		var (
+			samples []float64
+			latest  float64
+		)
+		for _, sample := range v.Values {
+			ts, value, err := parseSample(sample)
+			if err != nil {
+				log.Errorln("prometheus 结果转换错误: ", err.Error())
+				continue
+			}
+			samples = append(samples, value)
+			latest = ts
+		}
+		if len(samples) == 0 {
+			continue
+		}
+
+		value := aggregate(r, samples)
+		if current, exist := values[nodeName]; exist {
+			log.Debugf("prometheus 查询 %v 结果中 node %v 有多个 series,取最接近过滤条件的值", m.Name, nodeName)
+			if !worse(m, value, current) {
+				continue
+			}
+		}
+		values[nodeName] = value
+		timestamps[nodeName] = latest
+	}
+
+	return values, timestamps, nil
+}
+
+func requestPrometheus(client *http.Client, endpoint, path string, q url.Values) (*PrometheusResult, error) {
+	urlStr := strings.TrimSuffix(endpoint, "/") + path
+	urlParse, err := url.Parse(urlStr)
+	if err != nil {
+		log.Errorln("prometheus url 格式错误: ", err.Error())
+		return nil, err
+	}
 	urlParse.RawQuery = q.Encode()
 	urlStr = urlParse.String()
 
-	log.Debugln("从 prometheus 查询 node 内存信息,url: ", urlStr)
+	log.Debugln("从 prometheus 查询 node 信息,url: ", urlStr)
 
-	resp, err := util.GetResponse("GET", urlStr, "", "Content-Type=application/json", "", 30*time.Second, nil)
+	req, _ := http.NewRequest("GET", urlStr, nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
 	if err != nil {
-		metrics.FromPrometheusGetDataError.WithLabelValues().Inc()
 		log.Errorln("http 请求 prometheus 出错: ", err.Error())
-		return
+		return nil, err
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		log.Errorln("http 请求 prometheus 出错, 错误码: ", resp.StatusCode)
+		return nil, errors.New("访问出错，错误码为" + strconv.Itoa(resp.StatusCode))
+	}
+
 	var presult PrometheusResult
 	result, _ := ioutil.ReadAll(resp.Body)
 	err = json.Unmarshal(result, &presult)
 	if err != nil {
-		metrics.FromPrometheusGetDataError.WithLabelValues().Inc()
 		log.Errorln("json 格式化 resp.Body 出错: ", err.Error())
-		return
+		return nil, err
 	}
 
-	if presult.Status == "success" {
-		currentTime := time.Now()
-		for _, v := range presult.Data.Result {
-			int, err := strconv.Atoi(strings.Split(v.Value[1].(string), ".")[0])
-			if err != nil {
-				log.Errorln("prometheus 结果转换错误: ", err.Error())
-			}
-			// 定时任务加锁更改
-			n.Lock.Lock()
-			n.NodeMem[v.Metric.Instance] = &NodeMemory{
-				NodeName:  v.Metric.Instance,
-				Value:     int,
-				CheckTime: currentTime,
+	if presult.Status != "success" {
+		log.Errorln("prometheus 查询出错")
+		return nil, errors.New("prometheus 查询出错, status: " + presult.Status)
+	}
+
+	return &presult, nil
+}
+
+// parseSample 解析 [时间戳, "值"] 格式的样本
+func parseSample(sample []interface{}) (float64, float64, error) {
+	if len(sample) < 2 {
+		return 0, 0, errors.New("样本格式错误")
+	}
+	ts, _ := sample[0].(float64)
+	s, _ := sample[1].(string)
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ts, value, nil
+}
+
+// fetchData 从数据源查询一个指标,更新缓存
+func (n *Nodes) fetchData(m conf.MetricConfig) error {
+	startGetDataEvalTime := time.Now()
+	defer func() {
+		metrics.FromPrometheusGetDataEvaluationDuration.WithLabelValues(m.Name).Observe(metrics.SinceInSeconds(startGetDataEvalTime))
+	}()
+
+	samples, err := n.fetchSamples(m)
+	if err != nil {
+		metrics.FromPrometheusGetDataError.WithLabelValues(m.Name).Inc()
+		return err
+	}
+
+	var capacity map[string]float64
+	if source, ok := n.source.(CapacitySource); ok && conf.Get().CapacityRequired(m) {
+		// 容量查询失败时沿用上一次的值
+		if capacity, err = source.FetchCapacity(m); err != nil {
+			log.Errorf("查询指标 %v 的节点容量出错: %v", m.Name, err)
+		}
+	}
+
+	currentTime := time.Now()
+	// 定时任务加锁更改
+	n.Lock.Lock()
+	nodes, exist := n.NodeMetrics[m.Name]
+	if !exist {
+		// 查询期间指标被热加载删除
+		n.Lock.Unlock()
+		return nil
+	}
+	n.lastSuccess[m.Name] = currentTime
+	for nodeName, sample := range samples {
+		value := sample.Value
+		prev, hasPrev := nodes[nodeName]
+		node := &NodeMetric{
+			NodeName:   nodeName,
+			Value:      value,
+			CheckTime:  currentTime,
+			SampleTime: sample.Time,
+		}
+		if c, exist := capacity[nodeName]; exist {
+			node.Capacity = c
+		} else if hasPrev {
+			node.Capacity = prev.Capacity
+		}
+		if m.Forecast != nil {
+			var history []Sample
+			// 数据中断过的历史不再用于预测
+			if hasPrev && prev.Fresh(currentTime) {
+				history = prev.History
 			}
-			n.Lock.Unlock()
+			node.History = appendHistory(history, Sample{Time: currentTime, Value: value}, m.Forecast.Samples)
+		}
+		if m.Hysteresis() {
+			node.Excluded = updateExclusion(MetricForNode(m, nil, nodeName), nodeName, hasPrev && prev.Excluded, value)
 		}
+		nodes[nodeName] = node
+	}
+	n.Lock.Unlock()
+	return nil
+}
+
+// fetchSamples 从数据源查询一个指标. 开启预留时只有内置 memory、cpu 指标需要样本时间,
+// 数据源不支持时样本时间为空
+func (n *Nodes) fetchSamples(m conf.MetricConfig) (map[string]Sample, error) {
+	if source, ok := n.source.(SampleSource); ok && conf.Get().Reservation.Enabled() && reservable(m.Name) {
+		return source.FetchSamples(m)
+	}
+
+	values, err := n.source.Fetch(m)
+	if err != nil {
+		return nil, err
+	}
+	samples := make(map[string]Sample, len(values))
+	for nodeName, value := range values {
+		samples[nodeName] = Sample{Value: value}
+	}
+	return samples, nil
+}
+
+// updateExclusion 按高低水位计算节点新的过滤状态,状态变化时记录日志和 metrics
+func updateExclusion(m conf.MetricConfig, nodeName string, excluded bool, value float64) bool {
+	switch {
+	case !excluded && m.Exceeds(value):
+		log.Infof("node %v %v 指标值 %v 超过高水位 %v,停止调度直到低于低水位 %v", nodeName, m.Name, formatValue(value), m.Threshold, m.RecoverThreshold)
+		metrics.NodeExclusionTransitions.WithLabelValues(m.Name, "excluded").Inc()
+		excluded = true
+	case excluded && m.Recovered(value):
+		log.Infof("node %v %v 指标值 %v 回落到低水位 %v 以内,恢复调度", nodeName, m.Name, formatValue(value), m.RecoverThreshold)
+		metrics.NodeExclusionTransitions.WithLabelValues(m.Name, "recovered").Inc()
+		excluded = false
+	}
+
+	if excluded {
+		metrics.NodeExcluded.WithLabelValues(m.Name, nodeName).Set(1)
 	} else {
-		metrics.FromPrometheusGetDataError.WithLabelValues().Inc()
-		log.Errorln("prometheus 查询出错")
+		metrics.NodeExcluded.WithLabelValues(m.Name, nodeName).Set(0)
 	}
+	return excluded
+}
 
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
 }
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"github.com/prometheus/common/log"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/metrics"
+	"kube-scheduler-extender/util"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+type prometheusSource struct {
+	client *http.Client
+}
+
+// NewPrometheusSource 返回 prometheus 数据源,支持任意 PromQL 指标,
+// 配置多个 endpoint 时按 Prometheus.Mode 做故障切换或结果合并
+func NewPrometheusSource(options util.HTTPClientOptions) (DataSource, error) {
+	client, err := util.NewHTTPClient(options)
+	if err != nil {
+		return nil, err
+	}
+	return &prometheusSource{client: client}, nil
+}
+
+func (p *prometheusSource) Name() string {
+	return PrometheusDataSource
+}
+
+func (p *prometheusSource) Supports(m conf.MetricConfig) bool {
+	return m.Query != ""
+}
+
+func (p *prometheusSource) Fetch(m conf.MetricConfig) (map[string]float64, error) {
+	r, err := p.fetch(m, false)
+	return r.values, err
+}
+
+// FetchSamples 同时返回每个 node 最新样本的时间戳,即时查询需要多执行一次 timestamp() 查询
+func (p *prometheusSource) FetchSamples(m conf.MetricConfig) (map[string]Sample, error) {
+	r, err := p.fetch(m, true)
+	if err != nil {
+		return nil, err
+	}
+	samples := make(map[string]Sample, len(r.values))
+	for nodeName, value := range r.values {
+		sample := Sample{Value: value}
+		if ts, exist := r.timestamps[nodeName]; exist {
+			sample.Time = time.Unix(0, int64(ts*float64(time.Second)))
+		}
+		samples[nodeName] = sample
+	}
+	return samples, nil
+}
+
+func (p *prometheusSource) fetch(m conf.MetricConfig, timestamps bool) (endpointResult, error) {
+	if c := conf.Get(); c.Prometheus.Mode == conf.PrometheusModeMerge && len(c.PrometheusUrls) > 1 {
+		return p.fetchMerge(m, timestamps)
+	}
+	return p.fetchFailover(m, timestamps)
+}
+
+// FetchCapacity 使用 CapacityQuery 做即时查询,多个 endpoint 时按顺序故障切换
+func (p *prometheusSource) FetchCapacity(m conf.MetricConfig) (map[string]float64, error) {
+	if m.CapacityQuery == "" {
+		return nil, fmt.Errorf("metric %v 没有配置 capacityQuery", m.Name)
+	}
+	capacity := m
+	capacity.Query = m.CapacityQuery
+	capacity.Range = nil
+	r, err := p.fetchFailover(capacity, false)
+	return r.values, err
+}
+
+// fetchFailover 按顺序查询 endpoint,返回第一个成功的结果
+func (p *prometheusSource) fetchFailover(m conf.MetricConfig, timestamps bool) (endpointResult, error) {
+	var lastErr error
+	for _, endpoint := range conf.Get().PrometheusUrls {
+		r, err := p.query(endpoint, m, timestamps)
+		if err == nil {
+			return r, nil
+		}
+		log.Warnf("prometheus endpoint %v 查询 %v 失败,尝试下一个 endpoint", endpointLabel(endpoint), m.Name)
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("没有配置 prometheus endpoint")
+	}
+	return endpointResult{}, lastErr
+}
+
+type endpointResult struct {
+	values     map[string]float64
+	timestamps map[string]float64
+}
+
+// fetchMerge 并发查询所有 endpoint,每个 node 按 MergeStrategy 选取一个值,
+// 返回的样本时间戳为选中的值所在 endpoint 的时间戳
+func (p *prometheusSource) fetchMerge(m conf.MetricConfig, timestamps bool) (endpointResult, error) {
+	c := conf.Get()
+	freshest := c.Prometheus.MergeStrategy == conf.MergeStrategyFreshest
+
+	var (
+		wg      sync.WaitGroup
+		lock    sync.Mutex
+		results []endpointResult
+		lastErr error
+	)
+	for _, endpoint := range c.PrometheusUrls {
+		wg.Add(1)
+		go func(endpoint string) {
+			defer wg.Done()
+
+			r, err := p.query(endpoint, m, freshest || timestamps)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				lastErr = err
+				return
+			}
+			results = append(results, r)
+		}(endpoint)
+	}
+	wg.Wait()
+
+	if len(results) == 0 {
+		return endpointResult{}, lastErr
+	}
+
+	merged := endpointResult{values: make(map[string]float64), timestamps: make(map[string]float64)}
+	for _, r := range results {
+		for nodeName, value := range r.values {
+			current, exist := merged.values[nodeName]
+			switch {
+			case !exist:
+			case freshest:
+				if r.timestamps[nodeName] <= merged.timestamps[nodeName] {
+					continue
+				}
+			case !worse(m, value, current):
+				continue
+			}
+			merged.values[nodeName] = value
+			if ts, exist := r.timestamps[nodeName]; exist {
+				merged.timestamps[nodeName] = ts
+			} else {
+				delete(merged.timestamps, nodeName)
+			}
+		}
+	}
+	return merged, nil
+}
+
+// worse 判断 a 是否比 b 更接近过滤条件
+func worse(m conf.MetricConfig, a, b float64) bool {
+	if m.Comparison == conf.ComparisonBelow {
+		return a < b
+	}
+	return a > b
+}
+
+// query 查询一个 endpoint 并记录 endpoint 健康状态,
+// timestamps 为 true 时同时返回每个 node 最新样本的时间戳
+func (p *prometheusSource) query(endpoint string, m conf.MetricConfig, timestamps bool) (endpointResult, error) {
+	label := endpointLabel(endpoint)
+	start := time.Now()
+	r, err := p.doQuery(endpoint, m, timestamps)
+	metrics.PrometheusEndpointDuration.WithLabelValues(label).Observe(metrics.SinceInSeconds(start))
+	if err != nil {
+		metrics.PrometheusEndpointUp.WithLabelValues(label).Set(0)
+		metrics.PrometheusEndpointError.WithLabelValues(label).Inc()
+		return r, err
+	}
+	metrics.PrometheusEndpointUp.WithLabelValues(label).Set(1)
+	return r, nil
+}
+
+func (p *prometheusSource) doQuery(endpoint string, m conf.MetricConfig, timestamps bool) (endpointResult, error) {
+	var (
+		r   endpointResult
+		err error
+	)
+	nodeLabel := conf.Get().NodeLabel(m)
+
+	if m.Range != nil {
+		r.values, r.timestamps, err = queryPrometheusRange(p.client, endpoint, nodeLabel, m)
+		return r, err
+	}
+
+	r.values, err = queryPrometheus(p.client, endpoint, m.Query, nodeLabel)
+	if err == nil && timestamps {
+		// 即时查询返回的是查询时间,样本时间需要通过 timestamp() 查询
+		r.timestamps, err = queryPrometheus(p.client, endpoint, "timestamp("+m.Query+")", nodeLabel)
+	}
+	return r, err
+}
+
+// endpointLabel 去掉 url 中的认证信息,用于日志和 metrics label
+func endpointLabel(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	u.User = nil
+	return u.String()
+}
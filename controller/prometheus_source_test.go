@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"encoding/json"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/util"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testPrometheus 即时查询返回 values, timestamp() 查询返回 timestamps, down 为 true 时返回 503
+type testPrometheus struct {
+	values     map[string]float64
+	timestamps map[string]float64
+	down       bool
+	requests   int32
+}
+
+func (p *testPrometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&p.requests, 1)
+	if p.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	samples := p.values
+	if strings.HasPrefix(r.URL.Query().Get("query"), "timestamp(") {
+		samples = p.timestamps
+	}
+	result := make([]map[string]interface{}, 0, len(samples))
+	for instance, value := range samples {
+		result = append(result, map[string]interface{}{
+			"metric": map[string]string{"instance": instance},
+			"value":  []interface{}{1600000000, strconv.FormatFloat(value, 'f', -1, 64)},
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   map[string]interface{}{"resultType": "vector", "result": result},
+	})
+}
+
+func TestPrometheusSourceFetch(t *testing.T) {
+	above := conf.MetricConfig{Name: "load", Query: "load", Comparison: conf.ComparisonAbove}
+	below := conf.MetricConfig{Name: "free", Query: "free", Comparison: conf.ComparisonBelow}
+
+	tests := []struct {
+		name          string
+		mode          string
+		mergeStrategy string
+		metric        conf.MetricConfig
+		endpoints     []*testPrometheus
+		want          map[string]float64
+		wantErr       bool
+		// wantRequests 每个 endpoint 收到的请求数
+		wantRequests []int32
+	}{
+		{
+			name:   "failover 第一个 endpoint 成功时不查询后面的",
+			mode:   conf.PrometheusModeFailover,
+			metric: above,
+			endpoints: []*testPrometheus{
+				{values: map[string]float64{"node-1": 10}},
+				{values: map[string]float64{"node-1": 20}},
+			},
+			want:         map[string]float64{"node-1": 10},
+			wantRequests: []int32{1, 0},
+		},
+		{
+			name:   "failover 切换到下一个 endpoint",
+			mode:   conf.PrometheusModeFailover,
+			metric: above,
+			endpoints: []*testPrometheus{
+				{down: true},
+				{values: map[string]float64{"node-1": 20}},
+			},
+			want:         map[string]float64{"node-1": 20},
+			wantRequests: []int32{1, 1},
+		},
+		{
+			name:      "failover 所有 endpoint 失败",
+			mode:      conf.PrometheusModeFailover,
+			metric:    above,
+			endpoints: []*testPrometheus{{down: true}, {down: true}},
+			wantErr:   true,
+		},
+		{
+			name:          "merge worst 取最大值并合并 node",
+			mode:          conf.PrometheusModeMerge,
+			mergeStrategy: conf.MergeStrategyWorst,
+			metric:        above,
+			endpoints: []*testPrometheus{
+				{values: map[string]float64{"node-1": 10, "node-2": 50}},
+				{values: map[string]float64{"node-1": 30, "node-3": 5}},
+			},
+			want: map[string]float64{"node-1": 30, "node-2": 50, "node-3": 5},
+		},
+		{
+			name:          "merge worst comparison 为 below 时取最小值",
+			mode:          conf.PrometheusModeMerge,
+			mergeStrategy: conf.MergeStrategyWorst,
+			metric:        below,
+			endpoints: []*testPrometheus{
+				{values: map[string]float64{"node-1": 10}},
+				{values: map[string]float64{"node-1": 30}},
+			},
+			want: map[string]float64{"node-1": 10},
+		},
+		{
+			name:          "merge freshest 取样本时间最新的值",
+			mode:          conf.PrometheusModeMerge,
+			mergeStrategy: conf.MergeStrategyFreshest,
+			metric:        above,
+			endpoints: []*testPrometheus{
+				{values: map[string]float64{"node-1": 10, "node-2": 40}, timestamps: map[string]float64{"node-1": 100, "node-2": 80}},
+				{values: map[string]float64{"node-1": 30, "node-2": 20}, timestamps: map[string]float64{"node-1": 90, "node-2": 95}},
+			},
+			want: map[string]float64{"node-1": 10, "node-2": 20},
+		},
+		{
+			name:          "merge 忽略失败的 endpoint",
+			mode:          conf.PrometheusModeMerge,
+			mergeStrategy: conf.MergeStrategyWorst,
+			metric:        above,
+			endpoints: []*testPrometheus{
+				{down: true},
+				{values: map[string]float64{"node-1": 30}},
+			},
+			want: map[string]float64{"node-1": 30},
+		},
+		{
+			name:          "merge 所有 endpoint 失败",
+			mode:          conf.PrometheusModeMerge,
+			mergeStrategy: conf.MergeStrategyWorst,
+			metric:        above,
+			endpoints:     []*testPrometheus{{down: true}, {down: true}},
+			wantErr:       true,
+		},
+	}
+
+	source, err := NewPrometheusSource(util.HTTPClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var urls []string
+			for _, endpoint := range tt.endpoints {
+				server := httptest.NewServer(endpoint)
+				defer server.Close()
+				urls = append(urls, server.URL)
+			}
+			setTestConfig(t, &conf.Config{
+				PrometheusUrls: urls,
+				Prometheus:     conf.PrometheusConfig{Mode: tt.mode, MergeStrategy: tt.mergeStrategy},
+				NodeName:       conf.NodeNameConfig{Label: "instance"},
+			})
+
+			got, err := source.Fetch(tt.metric)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Fetch() = %v, 期望 %v", got, tt.want)
+			}
+			for i, want := range tt.wantRequests {
+				if got := atomic.LoadInt32(&tt.endpoints[i].requests); got != want {
+					t.Errorf("endpoint %v 请求数 %v, 期望 %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrometheusSourceFetchSamples(t *testing.T) {
+	m := conf.MetricConfig{Name: conf.MemoryMetricName, Query: "memory", Comparison: conf.ComparisonAbove}
+
+	tests := []struct {
+		name      string
+		mode      string
+		endpoints []*testPrometheus
+		want      map[string]Sample
+	}{
+		{
+			name: "failover 通过 timestamp() 查询样本时间",
+			mode: conf.PrometheusModeFailover,
+			endpoints: []*testPrometheus{
+				{values: map[string]float64{"node-1": 10, "node-2": 20}, timestamps: map[string]float64{"node-1": 1600000000.5}},
+			},
+			want: map[string]Sample{
+				"node-1": {Time: time.Unix(1600000000, 5e8), Value: 10},
+				// 没有样本时间的 node 只按 TTL 释放预留
+				"node-2": {Value: 20},
+			},
+		},
+		{
+			name: "merge 使用选中值所在 endpoint 的样本时间",
+			mode: conf.PrometheusModeMerge,
+			endpoints: []*testPrometheus{
+				{values: map[string]float64{"node-1": 10}, timestamps: map[string]float64{"node-1": 1600000090}},
+				{values: map[string]float64{"node-1": 30}, timestamps: map[string]float64{"node-1": 1600000060}},
+			},
+			want: map[string]Sample{"node-1": {Time: time.Unix(1600000060, 0), Value: 30}},
+		},
+	}
+
+	source, err := NewPrometheusSource(util.HTTPClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var urls []string
+			for _, endpoint := range tt.endpoints {
+				server := httptest.NewServer(endpoint)
+				defer server.Close()
+				urls = append(urls, server.URL)
+			}
+			setTestConfig(t, &conf.Config{
+				PrometheusUrls: urls,
+				Prometheus:     conf.PrometheusConfig{Mode: tt.mode, MergeStrategy: conf.MergeStrategyWorst},
+				NodeName:       conf.NodeNameConfig{Label: "instance"},
+			})
+
+			got, err := source.(SampleSource).FetchSamples(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("FetchSamples() = %v, 期望 %v", got, tt.want)
+			}
+			for nodeName, want := range tt.want {
+				if s := got[nodeName]; s.Value != want.Value || !s.Time.Equal(want.Time) {
+					t.Errorf("node %v 样本 %+v, 期望 %+v", nodeName, s, want)
+				}
+			}
+		})
+	}
+}
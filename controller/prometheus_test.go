@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"kube-scheduler-extender/conf"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestUpdateExclusion(t *testing.T) {
+	above := conf.MetricConfig{Name: "load", Comparison: conf.ComparisonAbove, Threshold: 80, RecoverThreshold: 60}
+	below := conf.MetricConfig{Name: "free", Comparison: conf.ComparisonBelow, Threshold: 10, RecoverThreshold: 30}
+
+	tests := []struct {
+		name   string
+		metric conf.MetricConfig
+		values []float64
+		// want 每个值之后的过滤状态
+		want []bool
+	}{
+		{
+			name:   "超过高水位后直到低于低水位才恢复",
+			metric: above,
+			values: []float64{50, 85, 70, 61, 59, 70},
+			want:   []bool{false, true, true, true, false, false},
+		},
+		{
+			name:   "等于高水位即过滤,等于低水位不恢复",
+			metric: above,
+			values: []float64{80, 60, 59.9},
+			want:   []bool{true, true, false},
+		},
+		{
+			name:   "below 方向低于高水位过滤,高于低水位恢复",
+			metric: below,
+			values: []float64{50, 10, 20, 30, 31, 20},
+			want:   []bool{false, true, true, true, false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			excluded := false
+			for i, value := range tt.values {
+				excluded = updateExclusion(tt.metric, "node-1", excluded, value)
+				if excluded != tt.want[i] {
+					t.Fatalf("第 %v 个值 %v 之后过滤状态 %v, 期望 %v", i, value, excluded, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNodeMetricUsable(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		policy     string
+		age        time.Duration
+		wantFresh  bool
+		wantUsable bool
+	}{
+		{name: "allow 未过期", policy: conf.StalePolicyAllow, age: 30 * time.Second, wantFresh: true, wantUsable: true},
+		{name: "allow 过期", policy: conf.StalePolicyAllow, age: 90 * time.Second},
+		{name: "reject 过期", policy: conf.StalePolicyReject, age: 90 * time.Second},
+		{name: "keep-last 宽限期内仍可用于预选", policy: conf.StalePolicyKeepLast, age: 90 * time.Second, wantUsable: true},
+		{name: "keep-last 超过宽限期", policy: conf.StalePolicyKeepLast, age: 3 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, &conf.Config{
+				Staleness: conf.StalenessConfig{Policy: tt.policy, GracePeriod: time.Minute},
+				Intervals: conf.IntervalsConfig{Overdue: time.Minute},
+			})
+			m := &NodeMetric{CheckTime: now.Add(-tt.age)}
+			if got := m.Fresh(now); got != tt.wantFresh {
+				t.Errorf("Fresh() = %v, 期望 %v", got, tt.wantFresh)
+			}
+			if got := m.Usable(now); got != tt.wantUsable {
+				t.Errorf("Usable() = %v, 期望 %v", got, tt.wantUsable)
+			}
+		})
+	}
+}
+
+func TestFlushOverdueNode(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		// want flush 之后保留的 node
+		want         []string
+		wantDegraded []string
+	}{
+		{name: "allow 删除过期数据", policy: conf.StalePolicyAllow, want: []string{"fresh"}, wantDegraded: []string{"stale"}},
+		{name: "keep-last 宽限期内保留", policy: conf.StalePolicyKeepLast, want: []string{"fresh", "grace"}, wantDegraded: []string{"stale"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, &conf.Config{
+				Metrics:   []conf.MetricConfig{{Name: "ok"}, {Name: "stale"}},
+				Staleness: conf.StalenessConfig{Policy: tt.policy, GracePeriod: time.Minute},
+				Intervals: conf.IntervalsConfig{Overdue: time.Minute},
+			})
+			now := time.Now()
+			n := &Nodes{
+				source: NewMetricsServerSource(nil, nil, time.Second),
+				NodeMetrics: map[string]map[string]*NodeMetric{
+					"ok": {
+						"fresh": {NodeName: "fresh", CheckTime: now},
+						"grace": {NodeName: "grace", CheckTime: now.Add(-90 * time.Second)},
+						"gone":  {NodeName: "gone", CheckTime: now.Add(-3 * time.Minute)},
+					},
+					"stale": {},
+				},
+				lastSuccess: map[string]time.Time{"ok": now, "stale": now.Add(-2 * time.Minute)},
+			}
+
+			n.flushOverdueNode()
+			var got []string
+			for nodeName := range n.NodeMetrics["ok"] {
+				got = append(got, nodeName)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flush 之后保留 %v, 期望 %v", got, tt.want)
+			}
+			if got := n.Degraded(); !reflect.DeepEqual(got, tt.wantDegraded) {
+				t.Errorf("Degraded() = %v, 期望 %v", got, tt.wantDegraded)
+			}
+		})
+	}
+}
+
+// sampleSource 返回固定样本时间的数据源
+type sampleSource struct {
+	sampleTime time.Time
+}
+
+func (s sampleSource) Name() string {
+	return "sample"
+}
+
+func (s sampleSource) Supports(m conf.MetricConfig) bool {
+	return true
+}
+
+func (s sampleSource) Fetch(m conf.MetricConfig) (map[string]float64, error) {
+	return map[string]float64{"node-1": 50}, nil
+}
+
+func (s sampleSource) FetchSamples(m conf.MetricConfig) (map[string]Sample, error) {
+	return map[string]Sample{"node-1": {Time: s.sampleTime, Value: 50}}, nil
+}
+
+func TestFetchDataSampleTime(t *testing.T) {
+	sampleTime := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name        string
+		metric      string
+		reservation conf.ReservationConfig
+		want        time.Time
+	}{
+		{name: "开启预留时记录样本时间", metric: conf.MemoryMetricName, reservation: conf.ReservationConfig{TTL: time.Minute}, want: sampleTime},
+		{name: "没有开启预留", metric: conf.MemoryMetricName},
+		{name: "预留不支持的指标", metric: "load", reservation: conf.ReservationConfig{TTL: time.Minute}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, &conf.Config{Reservation: tt.reservation})
+			n := &Nodes{
+				source:      sampleSource{sampleTime: sampleTime},
+				NodeMetrics: map[string]map[string]*NodeMetric{tt.metric: {}},
+				lastSuccess: make(map[string]time.Time),
+			}
+			if err := n.fetchData(conf.MetricConfig{Name: tt.metric}); err != nil {
+				t.Fatal(err)
+			}
+			got := n.NodeMetrics[tt.metric]["node-1"]
+			if got == nil || !got.SampleTime.Equal(tt.want) {
+				t.Fatalf("NodeMetric = %+v, 期望样本时间 %v", got, tt.want)
+			}
+			if got.CheckTime.Before(sampleTime) {
+				t.Errorf("CheckTime %v 应为查询时间", got.CheckTime)
+			}
+		})
+	}
+}
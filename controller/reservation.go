@@ -0,0 +1,263 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"github.com/prometheus/common/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/metrics"
+	"sync"
+	"time"
+)
+
+// PodReservations 最近调度到各个 node 上的 pod,用来弥补监控数据的延迟,
+// 避免数据更新之前同一个 node 一直得分最高,大量 pod 调度到同一个 node
+var PodReservations = NewReservations()
+
+// reservation 一个 pod 在 node 上预留的资源
+type reservation struct {
+	nodeName string
+	// memory 内存请求,单位 byte
+	memory int64
+	// cpu CPU 请求,单位 millicore
+	cpu  int64
+	time time.Time
+}
+
+type Reservations struct {
+	lock sync.RWMutex
+	// nodes node 名 -> pod uid -> 预留
+	nodes map[string]map[types.UID]*reservation
+	pods  map[types.UID]*reservation
+}
+
+func NewReservations() *Reservations {
+	return &Reservations{
+		nodes: make(map[string]map[types.UID]*reservation),
+		pods:  make(map[types.UID]*reservation),
+	}
+}
+
+// Reserve 记录 pod 调度到 node,重复调用时保留第一次的时间
+func (r *Reservations) Reserve(pod *v1.Pod, nodeName string, t time.Time) {
+	if !conf.Get().Reservation.Enabled() || time.Since(t) >= conf.Get().Reservation.TTL {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if old, exist := r.pods[pod.UID]; exist {
+		if old.nodeName == nodeName {
+			return
+		}
+		r.remove(pod.UID)
+	}
+
+	memory, cpu := PodRequests(pod)
+	v := &reservation{
+		nodeName: nodeName,
+		memory:   memory,
+		cpu:      cpu,
+		time:     t,
+	}
+	if r.nodes[nodeName] == nil {
+		r.nodes[nodeName] = make(map[types.UID]*reservation)
+	}
+	r.nodes[nodeName][pod.UID] = v
+	r.pods[pod.UID] = v
+	metrics.Reservations.Set(float64(len(r.pods)))
+
+	log.Debugf("pod %v/%v 预留 node %v, memory: %v, cpu: %vm", pod.Name, pod.Namespace, nodeName, memory, cpu)
+}
+
+// Release 删除 pod 的预留
+func (r *Reservations) Release(uid types.UID) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.remove(uid)
+	metrics.Reservations.Set(float64(len(r.pods)))
+}
+
+func (r *Reservations) remove(uid types.UID) {
+	v, exist := r.pods[uid]
+	if !exist {
+		return
+	}
+	delete(r.pods, uid)
+	delete(r.nodes[v.nodeName], uid)
+	if len(r.nodes[v.nodeName]) == 0 {
+		delete(r.nodes, v.nodeName)
+	}
+}
+
+// reservable 预留是否对指标生效,只支持内置的 memory、cpu 指标
+func reservable(metric string) bool {
+	return metric == conf.MemoryMetricName || metric == conf.CPUMetricName
+}
+
+// Load 返回 node 上未过期的预留占 allocatable 的百分比,只对内置的 memory、cpu 指标生效.
+// since 之前的预留已经体现在 node 指标数据中,不再重复计算,为空时计算所有未过期的预留
+func (r *Reservations) Load(metric, nodeName string, since, now time.Time) float64 {
+	if !conf.Get().Reservation.Enabled() || !reservable(metric) {
+		return 0
+	}
+
+	var memory, cpu int64
+	r.lock.RLock()
+	for _, v := range r.nodes[nodeName] {
+		if v.time.After(since) && now.Sub(v.time) < conf.Get().Reservation.TTL {
+			memory += v.memory
+			cpu += v.cpu
+		}
+	}
+	r.lock.RUnlock()
+
+	// node 不在 informer 缓存中时无法换算,不计算预留
+	load, _ := RequestsLoad(metric, nodeName, memory, cpu)
+	return load
+}
+
+// RequestsLoad 把内存(byte)、CPU(millicore)请求按 node allocatable 换算为内置 memory、cpu 指标的百分比,
+// 其他指标返回 0, 没有 node informer 或者 node 不在 informer 缓存中时返回错误
+func RequestsLoad(metric, nodeName string, memory, cpu int64) (float64, error) {
+	if (metric != conf.MemoryMetricName && metric != conf.CPUMetricName) || (memory == 0 && cpu == 0) {
+		return 0, nil
+	}
+	if nodeLister == nil {
+		return 0, errors.New("没有 node informer")
+	}
+
+	node, err := nodeLister.Get(nodeName)
+	if err != nil {
+		return 0, err
+	}
+	requested, allocatable := memory, node.Status.Allocatable.Memory().Value()
+	if metric == conf.CPUMetricName {
+		requested, allocatable = cpu, node.Status.Allocatable.Cpu().MilliValue()
+	}
+	if requested == 0 {
+		return 0, nil
+	}
+	if allocatable == 0 {
+		return 0, fmt.Errorf("node %v 没有 allocatable %v", nodeName, metric)
+	}
+	return float64(requested) / float64(allocatable) * 100, nil
+}
+
+// expire 删除过期的预留
+func (r *Reservations) expire() {
+	now := time.Now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for uid, v := range r.pods {
+		if now.Sub(v.time) >= conf.Get().Reservation.TTL {
+			r.remove(uid)
+		}
+	}
+	metrics.Reservations.Set(float64(len(r.pods)))
+}
+
+// PodRequests 计算 pod 的内存(byte)和 CPU(millicore)请求,init container 取最大值
+func PodRequests(pod *v1.Pod) (int64, int64) {
+	memory, cpu := resource.Quantity{}, resource.Quantity{}
+	for _, c := range pod.Spec.Containers {
+		memory.Add(*c.Resources.Requests.Memory())
+		cpu.Add(*c.Resources.Requests.Cpu())
+	}
+	for _, c := range pod.Spec.InitContainers {
+		if c.Resources.Requests.Memory().Cmp(memory) > 0 {
+			memory = *c.Resources.Requests.Memory()
+		}
+		if c.Resources.Requests.Cpu().Cmp(cpu) > 0 {
+			cpu = *c.Resources.Requests.Cpu()
+		}
+	}
+	return memory.Value(), cpu.MilliValue()
+}
+
+// StartPodInformer 启动 pod informer,记录新调度的 pod,pod 删除或结束后释放预留
+func StartPodInformer(client kubernetes.Interface, stopCh <-chan struct{}) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+		options.FieldSelector = "status.phase!=" + string(v1.PodSucceeded) + ",status.phase!=" + string(v1.PodFailed)
+	}))
+	podInformer := factory.Core().V1().Pods()
+	informer := podInformer.Informer()
+	if err := informer.AddIndexers(cache.Indexers{podUIDIndex: podUIDIndexFunc}); err != nil {
+		return err
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			pod := obj.(*v1.Pod)
+			if pod.Spec.NodeName != "" {
+				PodReservations.Reserve(pod, pod.Spec.NodeName, podScheduledTime(pod))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, pod := oldObj.(*v1.Pod), newObj.(*v1.Pod)
+			switch {
+			case pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed:
+				PodReservations.Release(pod.UID)
+			case oldPod.Spec.NodeName == "" && pod.Spec.NodeName != "":
+				PodReservations.Reserve(pod, pod.Spec.NodeName, time.Now())
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*v1.Pod); ok {
+				PodReservations.Release(pod.UID)
+			}
+		},
+	})
+
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+		return errors.New("等待 pod informer 同步超时")
+	}
+	go wait.Until(PodReservations.expire, 30*time.Second, stopCh)
+
+	podLister = podInformer.Lister()
+	podIndexer = informer.GetIndexer()
+
+	log.Infoln("pod informer 同步完成")
+	return nil
+}
+
+// podScheduledTime 返回 pod 的调度时间,没有 PodScheduled condition 时使用创建时间
+func podScheduledTime(pod *v1.Pod) time.Time {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodScheduled && c.Status == v1.ConditionTrue {
+			return c.LastTransitionTime.Time
+		}
+	}
+	return pod.CreationTimestamp.Time
+}
+
+func podUIDIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return nil, nil
+	}
+	return []string{string(pod.UID)}, nil
+}
+
+// LookupPod 按 uid 从 pod informer 缓存中查找 pod, 没有启动 pod informer 时总是返回 false
+func LookupPod(uid string) (*v1.Pod, bool) {
+	if podIndexer == nil {
+		return nil, false
+	}
+	objs, err := podIndexer.ByIndex(podUIDIndex, uid)
+	if err != nil || len(objs) == 0 {
+		return nil, false
+	}
+	return objs[0].(*v1.Pod), true
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"kube-scheduler-extender/conf"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReservationsLoad(t *testing.T) {
+	setTestConfig(t, &conf.Config{Reservation: conf.ReservationConfig{TTL: time.Minute}})
+	setTestNodeLister(t, newTestNode("node-1", v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("2"),
+		v1.ResourceMemory: resource.MustParse("4Gi"),
+	}))
+
+	now := time.Now()
+	r := NewReservations()
+	r.Reserve(newTestPod("default", "a", "uid-a", "1Gi", "500m"), "node-1", now.Add(-30*time.Second))
+	r.Reserve(newTestPod("default", "b", "uid-b", "512Mi", "250m"), "node-1", now.Add(-10*time.Second))
+	// 超过 TTL 的 pod 不记录预留
+	r.Reserve(newTestPod("default", "c", "uid-c", "2Gi", "1"), "node-1", now.Add(-2*time.Minute))
+
+	tests := []struct {
+		name       string
+		metric     string
+		nodeName   string
+		sampleTime time.Time
+		now        time.Time
+		want       float64
+	}{
+		{name: "没有指标数据时计算所有未过期的预留", metric: conf.MemoryMetricName, nodeName: "node-1", now: now, want: 37.5},
+		{name: "cpu 按 allocatable 换算", metric: conf.CPUMetricName, nodeName: "node-1", now: now, want: 37.5},
+		{name: "指标数据之前的预留已经体现在数据中", metric: conf.MemoryMetricName, nodeName: "node-1", sampleTime: now.Add(-20 * time.Second), now: now, want: 12.5},
+		{name: "指标数据比所有预留新", metric: conf.MemoryMetricName, nodeName: "node-1", sampleTime: now, now: now, want: 0},
+		{name: "超过 TTL 的预留不计算", metric: conf.MemoryMetricName, nodeName: "node-1", now: now.Add(40 * time.Second), want: 12.5},
+		{name: "自定义指标不计算预留", metric: "load", nodeName: "node-1", now: now, want: 0},
+		{name: "没有预留的 node", metric: conf.MemoryMetricName, nodeName: "node-2", now: now, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Load(tt.metric, tt.nodeName, tt.sampleTime, tt.now); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Load() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+
+	r.Release("uid-a")
+	if got := r.Load(conf.MemoryMetricName, "node-1", time.Time{}, now); math.Abs(got-12.5) > 1e-9 {
+		t.Errorf("Release 之后 Load() = %v, 期望 12.5", got)
+	}
+
+	setTestConfig(t, &conf.Config{})
+	if got := r.Load(conf.MemoryMetricName, "node-1", time.Time{}, now); got != 0 {
+		t.Errorf("关闭预留后 Load() = %v, 期望 0", got)
+	}
+}
+
+func TestPodRequests(t *testing.T) {
+	pod := newTestPod("default", "web", "uid-web", "1Gi", "500m")
+	pod.Spec.Containers = append(pod.Spec.Containers, newTestPod("", "", "", "512Mi", "250m").Spec.Containers...)
+
+	tests := []struct {
+		name       string
+		init       *v1.Pod
+		wantMemory int64
+		wantCPU    int64
+	}{
+		{name: "容器请求相加", wantMemory: 3 << 29, wantCPU: 750},
+		{name: "init container 请求更小", init: newTestPod("", "", "", "1Gi", "100m"), wantMemory: 3 << 29, wantCPU: 750},
+		{name: "init container 请求更大时取最大值", init: newTestPod("", "", "", "2Gi", "1"), wantMemory: 2 << 30, wantCPU: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := pod.DeepCopy()
+			if tt.init != nil {
+				p.Spec.InitContainers = tt.init.Spec.Containers
+			}
+			memory, cpu := PodRequests(p)
+			if memory != tt.wantMemory || cpu != tt.wantCPU {
+				t.Errorf("PodRequests() = %v, %vm, 期望 %v, %vm", memory, cpu, tt.wantMemory, tt.wantCPU)
+			}
+		})
+	}
+}
+
+func TestNodeMetricReserved(t *testing.T) {
+	setTestConfig(t, &conf.Config{Reservation: conf.ReservationConfig{TTL: time.Minute}})
+	setTestNodeLister(t, newTestNode("node-1", v1.ResourceList{v1.ResourceMemory: resource.MustParse("4Gi")}))
+	oldReservations := PodReservations
+	t.Cleanup(func() { PodReservations = oldReservations })
+
+	now := time.Now()
+	PodReservations = NewReservations()
+	PodReservations.Reserve(newTestPod("default", "a", "uid-a", "1Gi", "500m"), "node-1", now.Add(-30*time.Second))
+	PodReservations.Reserve(newTestPod("default", "b", "uid-b", "512Mi", "250m"), "node-1", now.Add(-10*time.Second))
+
+	tests := []struct {
+		name      string
+		metric    *NodeMetric
+		sampleLag time.Duration
+		want      float64
+	}{
+		{name: "缓存中没有数据", want: 37.5},
+		{name: "数据源没有样本时间时只按 TTL 释放", metric: &NodeMetric{CheckTime: now}, want: 37.5},
+		{
+			// 查询时间晚于两个预留, 但样本早于 pod b 的调度时间
+			name:   "查询时间更新但样本时间更早",
+			metric: &NodeMetric{CheckTime: now, SampleTime: now.Add(-20 * time.Second)},
+			want:   12.5,
+		},
+		{
+			name:      "样本时间之前 SampleLag 内的预留仍然计算",
+			metric:    &NodeMetric{CheckTime: now, SampleTime: now.Add(-20 * time.Second)},
+			sampleLag: 15 * time.Second,
+			want:      37.5,
+		},
+		{name: "样本比所有预留新", metric: &NodeMetric{CheckTime: now, SampleTime: now}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfig(t, &conf.Config{Reservation: conf.ReservationConfig{TTL: time.Minute, SampleLag: tt.sampleLag}})
+			if got := tt.metric.Reserved(conf.MemoryMetricName, "node-1", now); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Reserved() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestsLoad(t *testing.T) {
+	setTestNodeLister(t,
+		newTestNode("node-1", v1.ResourceList{v1.ResourceMemory: resource.MustParse("4Gi")}),
+	)
+
+	tests := []struct {
+		name     string
+		metric   string
+		nodeName string
+		memory   int64
+		cpu      int64
+		want     float64
+		wantErr  bool
+	}{
+		{name: "内存", metric: conf.MemoryMetricName, nodeName: "node-1", memory: 1 << 30, want: 25},
+		{name: "没有请求", metric: conf.MemoryMetricName, nodeName: "node-1", want: 0},
+		{name: "自定义指标", metric: "load", nodeName: "node-1", memory: 1 << 30, want: 0},
+		{name: "没有 allocatable", metric: conf.CPUMetricName, nodeName: "node-1", cpu: 500, wantErr: true},
+		{name: "node 不在缓存中", metric: conf.MemoryMetricName, nodeName: "node-2", memory: 1 << 30, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RequestsLoad(tt.metric, tt.nodeName, tt.memory, tt.cpu)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RequestsLoad() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("RequestsLoad() = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+
+	nodeLister = nil
+	if _, err := RequestsLoad(conf.MemoryMetricName, "node-1", 1<<30, 0); err == nil {
+		t.Errorf("没有 node informer 时 RequestsLoad() 期望返回错误")
+	}
+}
@@ -4,11 +4,24 @@ go 1.13
 
 require (
 	github.com/arl/statsviz v0.1.0
+	github.com/google/go-cmp v0.5.8 // indirect
+	github.com/googleapis/gnostic v0.5.5 // indirect
+	github.com/hashicorp/golang-lru v0.5.4 // indirect
+	github.com/imdario/mergo v0.3.6 // indirect
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/prometheus/client_golang v1.7.1
 	github.com/prometheus/common v0.10.0
+	golang.org/x/net v0.11.0 // indirect
+	golang.org/x/oauth2 v0.0.0-20210113205817-d3ed898aa8a3 // indirect
+	golang.org/x/term v0.10.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/protobuf v1.27.1 // indirect
+	gopkg.in/alecthomas/kingpin.v2 v2.2.6
+	gopkg.in/yaml.v2 v2.2.8
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 	k8s.io/api v0.19.2
 	k8s.io/apimachinery v0.19.2
 	k8s.io/client-go v0.19.2
 	k8s.io/kube-scheduler v0.19.2
+	sigs.k8s.io/structured-merge-diff/v4 v4.1.0 // indirect
 )
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/arl/statsviz"
 	"gopkg.in/alecthomas/kingpin.v2"
+	"kube-scheduler-extender/algorithm"
 	"kube-scheduler-extender/conf"
 	"kube-scheduler-extender/controller"
 	"kube-scheduler-extender/routers"
@@ -20,11 +21,65 @@ func init() {
 }
 
 var (
-	prometheusUrl             = kingpin.Flag("prometheus_url", "Prometheus url. (env: PROMETHEUS_URL)").Default(util.GetEnv("PROMETHEUS_URL", "http://127.0.0.1:9090")).String()
-	prometheusMemoryMetrics   = kingpin.Flag("prometheus_memory_metrics", "Prometheus memory metrics. (env: PROMETHEUS_MEMORY_METRICS)").Default(util.GetEnv("PROMETHEUS_MEMORY_METRICS", "HostMemoryUsagePercent")).String()
-	prometheusMemoryThreshold = kingpin.Flag("prometheus_memory_threshold", "Prometheus memory threshold. (env: PROMETHEUS_MEMORY_THRESHOLD)").Default(util.GetEnv("PROMETHEUS_MEMORY_THRESHOLD", "80")).Int()
-	listenAddress             = kingpin.Flag("listen_address", "Address to listen on for web interface and telemetry. (env: LISTEN_ADDRESS)").Default(util.GetEnv("LISTEN_ADDRESS", ":8888")).String()
-	logRequestBody            = kingpin.Flag("log_request_body", "Log k8s request body. (env: LOG_REQUEST_BODY)").Default(util.GetEnv("LOG_REQUEST_BODY", "false")).Bool()
+	dataSource                      = kingpin.Flag("data_source", "Node load data source, prometheus, metrics-server or kubelet. (env: DATA_SOURCE)").Default(util.GetEnv("DATA_SOURCE", controller.PrometheusDataSource)).Enum(controller.PrometheusDataSource, controller.MetricsServerDataSource, controller.KubeletDataSource)
+	kubeletAccess                   = kingpin.Flag("kubelet_access", "How to reach kubelet summary api, proxy (through API server) or direct. (env: KUBELET_ACCESS)").Default(util.GetEnv("KUBELET_ACCESS", controller.KubeletAccessProxy)).Enum(controller.KubeletAccessProxy, controller.KubeletAccessDirect)
+	dataSourceTimeout               = kingpin.Flag("data_source_timeout", "Timeout of each query to the data source. (env: DATA_SOURCE_TIMEOUT)").Default(util.GetEnv("DATA_SOURCE_TIMEOUT", "30s")).Duration()
+	refreshInterval                 = kingpin.Flag("refresh_interval", "How often each metric is queried from the data source. (env: REFRESH_INTERVAL)").Default(util.GetEnv("REFRESH_INTERVAL", "60s")).Duration()
+	flushInterval                   = kingpin.Flag("flush_interval", "How often overdue node data is removed from the cache. (env: FLUSH_INTERVAL)").Default(util.GetEnv("FLUSH_INTERVAL", "30s")).Duration()
+	nodeOverdueTime                 = kingpin.Flag("node_overdue_time", "Node data not updated for this long is stale. (env: NODE_OVERDUE_TIME)").Default(util.GetEnv("NODE_OVERDUE_TIME", "180s")).Duration()
+	intervalJitter                  = kingpin.Flag("interval_jitter", "Randomly extend refresh and flush intervals by up to this factor, 0 disables. (env: INTERVAL_JITTER)").Default(util.GetEnv("INTERVAL_JITTER", "0.1")).Float64()
+	backoffMax                      = kingpin.Flag("backoff_max", "Refresh interval doubles on consecutive query failures up to this. (env: BACKOFF_MAX)").Default(util.GetEnv("BACKOFF_MAX", "5m")).Duration()
+	breakerThreshold                = kingpin.Flag("breaker_threshold", "Stop querying a metric for breaker_cooldown after this many consecutive failures, 0 disables. (env: BREAKER_THRESHOLD)").Default(util.GetEnv("BREAKER_THRESHOLD", "5")).Int()
+	breakerCooldown                 = kingpin.Flag("breaker_cooldown", "How long a metric is not queried after the circuit opens. (env: BREAKER_COOLDOWN)").Default(util.GetEnv("BREAKER_COOLDOWN", "5m")).Duration()
+	kubeletPort                     = kingpin.Flag("kubelet_port", "Kubelet port, used by direct access. (env: KUBELET_PORT)").Default(util.GetEnv("KUBELET_PORT", "10250")).Int()
+	kubeletInsecureTLS              = kingpin.Flag("kubelet_insecure_tls", "Do not verify kubelet serving certificate, used by direct access. (env: KUBELET_INSECURE_TLS)").Default(util.GetEnv("KUBELET_INSECURE_TLS", "false")).Bool()
+	kubeletWorkers                  = kingpin.Flag("kubelet_workers", "Number of nodes scraped concurrently, at least 1. (env: KUBELET_WORKERS)").Default(util.GetEnv("KUBELET_WORKERS", "16")).Int()
+	prometheusUrl                   = kingpin.Flag("prometheus_url", "Prometheus url, comma separated for multiple endpoints. (env: PROMETHEUS_URL)").Default(util.GetEnv("PROMETHEUS_URL", "http://127.0.0.1:9090")).String()
+	prometheusMode                  = kingpin.Flag("prometheus_mode", "How to query multiple prometheus endpoints, failover or merge. (env: PROMETHEUS_MODE)").Default(util.GetEnv("PROMETHEUS_MODE", conf.PrometheusModeFailover)).String()
+	prometheusMergeStrategy         = kingpin.Flag("prometheus_merge_strategy", "How to merge results of each node in merge mode, worst or freshest. (env: PROMETHEUS_MERGE_STRATEGY)").Default(util.GetEnv("PROMETHEUS_MERGE_STRATEGY", conf.MergeStrategyWorst)).String()
+	prometheusBearerToken           = kingpin.Flag("prometheus_bearer_token", "Bearer token for prometheus. (env: PROMETHEUS_BEARER_TOKEN)").Default(util.GetEnv("PROMETHEUS_BEARER_TOKEN", "")).String()
+	prometheusBearerTokenFile       = kingpin.Flag("prometheus_bearer_token_file", "File containing the bearer token for prometheus, re-read when it changes. (env: PROMETHEUS_BEARER_TOKEN_FILE)").Default(util.GetEnv("PROMETHEUS_BEARER_TOKEN_FILE", "")).String()
+	prometheusUsername              = kingpin.Flag("prometheus_basic_auth_username", "Basic auth username for prometheus. (env: PROMETHEUS_BASIC_AUTH_USERNAME)").Default(util.GetEnv("PROMETHEUS_BASIC_AUTH_USERNAME", "")).String()
+	prometheusPassword              = kingpin.Flag("prometheus_basic_auth_password", "Basic auth password for prometheus. (env: PROMETHEUS_BASIC_AUTH_PASSWORD)").Default(util.GetEnv("PROMETHEUS_BASIC_AUTH_PASSWORD", "")).String()
+	prometheusCAFile                = kingpin.Flag("prometheus_ca_file", "CA bundle to verify prometheus server certificate. (env: PROMETHEUS_CA_FILE)").Default(util.GetEnv("PROMETHEUS_CA_FILE", "")).String()
+	prometheusCertFile              = kingpin.Flag("prometheus_cert_file", "Client certificate for prometheus mTLS, reloaded when it changes. (env: PROMETHEUS_CERT_FILE)").Default(util.GetEnv("PROMETHEUS_CERT_FILE", "")).String()
+	prometheusKeyFile               = kingpin.Flag("prometheus_key_file", "Client key for prometheus mTLS, reloaded when it changes. (env: PROMETHEUS_KEY_FILE)").Default(util.GetEnv("PROMETHEUS_KEY_FILE", "")).String()
+	prometheusInsecure              = kingpin.Flag("prometheus_insecure_skip_verify", "Do not verify prometheus server certificate. (env: PROMETHEUS_INSECURE_SKIP_VERIFY)").Default(util.GetEnv("PROMETHEUS_INSECURE_SKIP_VERIFY", "false")).Bool()
+	prometheusHeaders               = kingpin.Flag("prometheus_headers", "Extra headers sent to prometheus, e.g. X-Scope-OrgID=tenant&X-Foo=bar. (env: PROMETHEUS_HEADERS)").Default(util.GetEnv("PROMETHEUS_HEADERS", "")).String()
+	prometheusMemoryMetrics         = kingpin.Flag("prometheus_memory_metrics", "Prometheus memory metrics. (env: PROMETHEUS_MEMORY_METRICS)").Default(util.GetEnv("PROMETHEUS_MEMORY_METRICS", "HostMemoryUsagePercent")).String()
+	prometheusMemoryThreshold       = kingpin.Flag("prometheus_memory_threshold", "Prometheus memory threshold. (env: PROMETHEUS_MEMORY_THRESHOLD)").Default(util.GetEnv("PROMETHEUS_MEMORY_THRESHOLD", "80")).Int()
+	prometheusCPUMetrics            = kingpin.Flag("prometheus_cpu_metrics", "Prometheus cpu metrics. (env: PROMETHEUS_CPU_METRICS)").Default(util.GetEnv("PROMETHEUS_CPU_METRICS", "HostCPUUsagePercent")).String()
+	prometheusCPUThreshold          = kingpin.Flag("prometheus_cpu_threshold", "Prometheus cpu threshold. (env: PROMETHEUS_CPU_THRESHOLD)").Default(util.GetEnv("PROMETHEUS_CPU_THRESHOLD", "80")).Int()
+	prometheusMemoryRecover         = kingpin.Flag("prometheus_memory_recover_threshold", "Low watermark of memory, a filtered node stays filtered until memory drops below it, 0 disables. (env: PROMETHEUS_MEMORY_RECOVER_THRESHOLD)").Default(util.GetEnv("PROMETHEUS_MEMORY_RECOVER_THRESHOLD", "0")).Int()
+	prometheusCPURecover            = kingpin.Flag("prometheus_cpu_recover_threshold", "Low watermark of cpu, a filtered node stays filtered until cpu drops below it, 0 disables. (env: PROMETHEUS_CPU_RECOVER_THRESHOLD)").Default(util.GetEnv("PROMETHEUS_CPU_RECOVER_THRESHOLD", "0")).Int()
+	memoryGuaranteedThreshold       = kingpin.Flag("prometheus_memory_guaranteed_threshold", "Memory threshold for Guaranteed pods, 0 uses prometheus_memory_threshold. (env: PROMETHEUS_MEMORY_GUARANTEED_THRESHOLD)").Default(util.GetEnv("PROMETHEUS_MEMORY_GUARANTEED_THRESHOLD", "0")).Int()
+	memoryBurstableThreshold        = kingpin.Flag("prometheus_memory_burstable_threshold", "Memory threshold for Burstable pods, 0 uses prometheus_memory_threshold. (env: PROMETHEUS_MEMORY_BURSTABLE_THRESHOLD)").Default(util.GetEnv("PROMETHEUS_MEMORY_BURSTABLE_THRESHOLD", "0")).Int()
+	memoryBestEffortThreshold       = kingpin.Flag("prometheus_memory_besteffort_threshold", "Memory threshold for BestEffort pods, 0 uses prometheus_memory_threshold. (env: PROMETHEUS_MEMORY_BESTEFFORT_THRESHOLD)").Default(util.GetEnv("PROMETHEUS_MEMORY_BESTEFFORT_THRESHOLD", "0")).Int()
+	prometheusMemoryCapacityMetrics = kingpin.Flag("prometheus_memory_capacity_metrics", "Prometheus node memory capacity metrics in bytes, used by memory_fit. (env: PROMETHEUS_MEMORY_CAPACITY_METRICS)").Default(util.GetEnv("PROMETHEUS_MEMORY_CAPACITY_METRICS", "HostMemoryTotalBytes")).String()
+	memoryFit                       = kingpin.Flag("memory_fit", "Filter nodes whose free memory under the threshold cannot hold the pod. (env: MEMORY_FIT)").Default(util.GetEnv("MEMORY_FIT", "false")).Bool()
+	memoryFitBasis                  = kingpin.Flag("memory_fit_basis", "Pod memory size used by memory_fit, requests or limits. (env: MEMORY_FIT_BASIS)").Default(util.GetEnv("MEMORY_FIT_BASIS", conf.MemoryFitRequests)).String()
+	metricsConfigFile               = kingpin.Flag("metrics_config_file", "Yaml file of custom prometheus metrics, each registers a predicate and a priority. (env: METRICS_CONFIG_FILE)").Default(util.GetEnv("METRICS_CONFIG_FILE", "")).String()
+	configFile                      = kingpin.Flag("config_file", "Versioned yaml or json config file, its fields override the flags and it is hot reloaded with the metrics and plugins config files. (env: CONFIG_FILE)").Default(util.GetEnv("CONFIG_FILE", "")).String()
+	configReloadInterval            = kingpin.Flag("config_reload_interval", "How often config files are checked for changes, 0 disables hot reload. (env: CONFIG_RELOAD_INTERVAL)").Default(util.GetEnv("CONFIG_RELOAD_INTERVAL", "10s")).Duration()
+	pluginsConfigFile               = kingpin.Flag("plugins_config_file", "Yaml file choosing which predicates run in what order and which priorities apply with what weight. (env: PLUGINS_CONFIG_FILE)").Default(util.GetEnv("PLUGINS_CONFIG_FILE", "")).String()
+	prometheusNodeLabel             = kingpin.Flag("prometheus_node_label", "Prometheus label used as node name. (env: PROMETHEUS_NODE_LABEL)").Default(util.GetEnv("PROMETHEUS_NODE_LABEL", "instance")).String()
+	nodeNameRegex                   = kingpin.Flag("node_name_regex", "Regex matched against the node label value, rewritten by node_name_replacement. (env: NODE_NAME_REGEX)").Default(util.GetEnv("NODE_NAME_REGEX", "")).String()
+	nodeNameReplacement             = kingpin.Flag("node_name_replacement", "Replacement for node_name_regex, supports $1 etc. (env: NODE_NAME_REPLACEMENT)").Default(util.GetEnv("NODE_NAME_REPLACEMENT", "$1")).String()
+	nodeLookup                      = kingpin.Flag("node_lookup", "Lookup node name by InternalIP/Hostname with a node informer. (env: NODE_LOOKUP)").Default(util.GetEnv("NODE_LOOKUP", "false")).Bool()
+	nodeThresholdAnnotations        = kingpin.Flag("node_threshold_annotations", "Read per-node thresholds from node annotations kube-scheduler-extender/<metric>-threshold. (env: NODE_THRESHOLD_ANNOTATIONS)").Default(util.GetEnv("NODE_THRESHOLD_ANNOTATIONS", "false")).Bool()
+	kubeconfig                      = kingpin.Flag("kubeconfig", "Path to kubeconfig, in-cluster config is used if empty. (env: KUBECONFIG)").Default(util.GetEnv("KUBECONFIG", "")).String()
+	stalePolicy                     = kingpin.Flag("stale_policy", "Filter policy when node load data is stale or missing, allow, reject or keep-last. (env: STALE_POLICY)").Default(util.GetEnv("STALE_POLICY", conf.StalePolicyAllow)).String()
+	staleGracePeriod                = kingpin.Flag("stale_grace_period", "How long the last known value is kept after it is stale, used by keep-last. (env: STALE_GRACE_PERIOD)").Default(util.GetEnv("STALE_GRACE_PERIOD", "5m")).Duration()
+	reservationTTL                  = kingpin.Flag("reservation_ttl", "How long requests of recently scheduled pods are added to the node memory/cpu load, 0 disables. (env: RESERVATION_TTL)").Default(util.GetEnv("RESERVATION_TTL", "0s")).Duration()
+	reservationSampleLag            = kingpin.Flag("reservation_sample_lag", "Pods scheduled within this long before the sample time of the node data are still reserved. (env: RESERVATION_SAMPLE_LAG)").Default(util.GetEnv("RESERVATION_SAMPLE_LAG", "30s")).Duration()
+	bypassNamespaces                = kingpin.Flag("bypass_namespaces", "Comma separated namespaces whose pods always bypass the load plugins. (env: BYPASS_NAMESPACES)").Default(util.GetEnv("BYPASS_NAMESPACES", "")).String()
+	bypassPriorityClasses           = kingpin.Flag("bypass_priority_classes", "Comma separated PriorityClasses whose pods always bypass the load plugins. (env: BYPASS_PRIORITY_CLASSES)").Default(util.GetEnv("BYPASS_PRIORITY_CLASSES", "")).String()
+	enableBind                      = kingpin.Flag("enable_bind", "Serve the extender bind verb at /bind, set bindVerb in the scheduler extender config to use it. (env: ENABLE_BIND)").Default(util.GetEnv("ENABLE_BIND", "false")).Bool()
+	enablePreempt                   = kingpin.Flag("enable_preempt", "Serve the extender preempt verb at /preempt, set preemptVerb in the scheduler extender config to use it, starts node and pod informers. (env: ENABLE_PREEMPT)").Default(util.GetEnv("ENABLE_PREEMPT", "false")).Bool()
+	adminToken                      = kingpin.Flag("admin_token", "Bearer token of the admin API at /admin/, the admin API is disabled if neither token nor token file is set. (env: ADMIN_TOKEN)").Default(util.GetEnv("ADMIN_TOKEN", "")).String()
+	adminTokenFile                  = kingpin.Flag("admin_token_file", "File containing the bearer token of the admin API, re-read when it changes. (env: ADMIN_TOKEN_FILE)").Default(util.GetEnv("ADMIN_TOKEN_FILE", "")).String()
+	listenAddress                   = kingpin.Flag("listen_address", "Address to listen on for web interface and telemetry. (env: LISTEN_ADDRESS)").Default(util.GetEnv("LISTEN_ADDRESS", ":8888")).String()
+	logRequestBody                  = kingpin.Flag("log_request_body", "Log k8s request body. (env: LOG_REQUEST_BODY)").Default(util.GetEnv("LOG_REQUEST_BODY", "false")).Bool()
 )
 
 func main() {
@@ -32,11 +87,158 @@ func main() {
 	kingpin.HelpFlag.Short('h')
 	kingpin.Parse()
 
-	conf.NewConfig(*prometheusUrl, *prometheusMemoryMetrics, *prometheusMemoryThreshold, *logRequestBody)
+	headers, err := util.ParseHeaders(*prometheusHeaders)
+	if err != nil {
+		log.Fatalln("prometheus_headers 配置出错: ", err)
+	}
+	options := conf.Options{
+		DataSource:                   *dataSource,
+		DataSourceTimeout:            *dataSourceTimeout,
+		PrometheusBearerToken:        *prometheusBearerToken,
+		PrometheusBearerTokenFile:    *prometheusBearerTokenFile,
+		PrometheusBasicAuthUsername:  *prometheusUsername,
+		PrometheusBasicAuthPassword:  *prometheusPassword,
+		PrometheusCAFile:             *prometheusCAFile,
+		PrometheusCertFile:           *prometheusCertFile,
+		PrometheusKeyFile:            *prometheusKeyFile,
+		PrometheusInsecureSkipVerify: *prometheusInsecure,
+		PrometheusHeaders:            headers,
+		KubeletAccess:                *kubeletAccess,
+		KubeletPort:                  *kubeletPort,
+		KubeletInsecureTLS:           *kubeletInsecureTLS,
+		KubeletWorkers:               *kubeletWorkers,
+		PrometheusUrl:                *prometheusUrl,
+		PrometheusMode:               *prometheusMode,
+		PrometheusMergeStrategy:      *prometheusMergeStrategy,
+		LogRequestBody:               *logRequestBody,
+		MemoryMetrics:                *prometheusMemoryMetrics,
+		MemoryThreshold:              *prometheusMemoryThreshold,
+		MemoryRecoverThreshold:       *prometheusMemoryRecover,
+		MemoryGuaranteedThreshold:    *memoryGuaranteedThreshold,
+		MemoryBurstableThreshold:     *memoryBurstableThreshold,
+		MemoryBestEffortThreshold:    *memoryBestEffortThreshold,
+		MemoryCapacityMetrics:        *prometheusMemoryCapacityMetrics,
+		CPUMetrics:                   *prometheusCPUMetrics,
+		CPUThreshold:                 *prometheusCPUThreshold,
+		CPURecoverThreshold:          *prometheusCPURecover,
+		MetricsConfigFile:            *metricsConfigFile,
+		PluginsConfigFile:            *pluginsConfigFile,
+		ConfigFile:                   *configFile,
+		NodeLabel:                    *prometheusNodeLabel,
+		NodeNameRegex:                *nodeNameRegex,
+		NodeNameReplacement:          *nodeNameReplacement,
+		NodeLookup:                   *nodeLookup,
+		NodeThresholdAnnotations:     *nodeThresholdAnnotations,
+		StalePolicy:                  *stalePolicy,
+		StaleGracePeriod:             *staleGracePeriod,
+		ReservationTTL:               *reservationTTL,
+		ReservationSampleLag:         *reservationSampleLag,
+		MemoryFit:                    *memoryFit,
+		MemoryFitBasis:               *memoryFitBasis,
+		BypassNamespaces:             *bypassNamespaces,
+		BypassPriorityClasses:        *bypassPriorityClasses,
+		RefreshInterval:              *refreshInterval,
+		FlushInterval:                *flushInterval,
+		OverdueTime:                  *nodeOverdueTime,
+		IntervalJitter:               *intervalJitter,
+		BackoffMax:                   *backoffMax,
+		BreakerThreshold:             *breakerThreshold,
+		BreakerCooldown:              *breakerCooldown,
+	}
+	c, err := conf.Build(options)
+	if err != nil {
+		log.Fatalln("加载配置出错: ", err)
+	}
+	conf.Store(c)
+	plugins, err := algorithm.NewPlugins(c)
+	if err != nil {
+		log.Fatalln("算法配置出错: ", err)
+	}
+	algorithm.SetPlugins(plugins)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	controller.NewNodeInfo(ctx.Done())
+
+	// 抢占需要 node informer 换算 victims 的 request, 需要 pod informer 按 uid 查找 victims
+	needNodeInformer := controller.NeedNodeInformer(c) || *enablePreempt
+	needPodInformer := c.Reservation.Enabled() || *enablePreempt
+	if needNodeInformer || *enableBind || c.DataSource.Type != controller.PrometheusDataSource {
+		if err := controller.NewKubeClient(*kubeconfig); err != nil {
+			log.Fatalln("创建 k8s client 出错: ", err)
+		}
+	}
+	if needNodeInformer {
+		if err := controller.StartNodeInformer(controller.KubeClient, ctx.Done()); err != nil {
+			log.Fatalln("启动 node informer 出错: ", err)
+		}
+	}
+	if needPodInformer {
+		if err := controller.StartPodInformer(controller.KubeClient, ctx.Done()); err != nil {
+			log.Fatalln("启动 pod informer 出错: ", err)
+		}
+	}
+
+	var source controller.DataSource
+	d := c.DataSource
+	switch d.Type {
+	case controller.MetricsServerDataSource:
+		source = controller.NewMetricsServerSource(controller.KubeClient, controller.KubeDynamicClient, d.Timeout)
+	case controller.KubeletDataSource:
+		source, err = controller.NewKubeletSource(controller.KubeClient, controller.KubeConfig, controller.KubeletOptions{
+			Access:                d.Kubelet.Access,
+			Port:                  d.Kubelet.Port,
+			InsecureSkipTLSVerify: d.Kubelet.InsecureSkipTLSVerify,
+			Workers:               d.Kubelet.Workers,
+			Timeout:               d.Timeout,
+		})
+		if err != nil {
+			log.Fatalln("创建 kubelet 数据源出错: ", err)
+		}
+	default:
+		source, err = controller.NewPrometheusSource(util.HTTPClientOptions{
+			BearerToken:        d.Prometheus.BearerToken,
+			BearerTokenFile:    d.Prometheus.BearerTokenFile,
+			BasicAuthUsername:  d.Prometheus.BasicAuthUsername,
+			BasicAuthPassword:  d.Prometheus.BasicAuthPassword,
+			CAFile:             d.Prometheus.CAFile,
+			CertFile:           d.Prometheus.CertFile,
+			KeyFile:            d.Prometheus.KeyFile,
+			InsecureSkipVerify: d.Prometheus.InsecureSkipVerify,
+			Headers:            d.Prometheus.Headers,
+			Timeout:            d.Timeout,
+		})
+		if err != nil {
+			log.Fatalln("创建 prometheus 数据源出错: ", err)
+		}
+	}
+	if err := controller.CheckConfig(source, c); err != nil {
+		log.Fatalln(err)
+	}
+	controller.NewNodeInfo(source, ctx.Done())
+	conf.Watch(options, *configReloadInterval, reloadConfig(source), ctx.Done())
+
+	if *enableBind {
+		routers.EnableBind()
+		log.Infoln("开启 bind, 由 extender 创建 Binding")
+	}
+	if *enablePreempt {
+		routers.EnablePreempt()
+		log.Infoln("开启 preempt, 过滤驱逐 victims 后负载仍然超过阈值的 node")
+	}
+	if *adminToken != "" || *adminTokenFile != "" {
+		token, err := util.NewTokenSource(*adminToken, *adminTokenFile)
+		if err != nil {
+			log.Fatalln("admin token 配置出错: ", err)
+		}
+		// explain 按 namespace/name 查询 pod 需要 k8s client, 创建失败时只能在请求中传递 pod
+		if controller.KubeClient == nil {
+			if err := controller.NewKubeClient(*kubeconfig); err != nil {
+				log.Warnln("创建 k8s client 出错, explain 不能按 namespace/name 查询 pod: ", err)
+			}
+		}
+		routers.EnableAdmin(token)
+		log.Infoln("开启 admin 接口 /admin/cache, /admin/config, /admin/health, /admin/explain")
+	}
 
 	go func() {
 		statsviz.RegisterDefault()
@@ -49,3 +251,27 @@ func main() {
 	http.ListenAndServe(*listenAddress, routers.Router)
 
 }
+
+// reloadConfig 检查配置文件变化后生成的新配置,通过后与算法一起替换,并按新的指标启停采集
+func reloadConfig(source controller.DataSource) func(*conf.Config) error {
+	return func(c *conf.Config) error {
+		if err := controller.CheckConfig(source, c); err != nil {
+			return err
+		}
+		if err := controller.CheckReload(c); err != nil {
+			return err
+		}
+		plugins, err := algorithm.NewPlugins(c)
+		if err != nil {
+			return err
+		}
+
+		conf.Lock.Lock()
+		conf.Store(c)
+		algorithm.SetPlugins(plugins)
+		conf.Lock.Unlock()
+
+		controller.NodeInfo.SyncMetrics()
+		return nil
+	}
+}
@@ -28,6 +28,14 @@ var (
 	PodSchedulePrioritySuccesses = scheduleAttempts.With(prometheus.Labels{"result": "success", "algorithm": "priority"})
 	// PodScheduleErrors counts how many pods could not be scheduled due to a scheduler error.
 	PodSchedulePriority = scheduleAttempts.With(prometheus.Labels{"result": "count", "algorithm": "priority"})
+	// PodBindSuccesses counts how many pods were bound by the extender.
+	PodBindSuccesses = scheduleAttempts.With(prometheus.Labels{"result": "success", "algorithm": "bind"})
+	// PodBind counts how many bind requests were received.
+	PodBind = scheduleAttempts.With(prometheus.Labels{"result": "count", "algorithm": "bind"})
+	// PodPreemptSuccesses counts how many preemption requests were processed.
+	PodPreemptSuccesses = scheduleAttempts.With(prometheus.Labels{"result": "success", "algorithm": "preempt"})
+	// PodPreempt counts how many preemption requests were received.
+	PodPreempt = scheduleAttempts.With(prometheus.Labels{"result": "count", "algorithm": "preempt"})
 
 	SchedulingAlgorithmPredicateEvaluationDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -50,19 +58,98 @@ var (
 			Name:    "from_prometheus_get_data_evaluation_seconds",
 			Help:    "From prometheus get data evaluation duration in seconds",
 			Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
-		}, []string{})
+		}, []string{"metric"})
 
 	FromPrometheusGetDataError = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "from_prometheus_get_data_error",
 			Help: "Number of attempts to from prometheus get data error.",
-		}, []string{})
+		}, []string{"metric"})
+
+	PrometheusEndpointUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "prometheus_endpoint_up",
+			Help: "Whether the last query to the prometheus endpoint succeeded.",
+		}, []string{"endpoint"})
+
+	PrometheusEndpointError = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "prometheus_endpoint_error",
+			Help: "Number of failed queries, by prometheus endpoint.",
+		}, []string{"endpoint"})
+
+	PrometheusEndpointDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "prometheus_endpoint_query_seconds",
+			Help:    "Query duration in seconds, by prometheus endpoint.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
+		}, []string{"endpoint"})
 
 	CacheSize = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "node_cache_size",
 			Help: "Number of nodes from prometheus search, in the cache.",
-		}, []string{})
+		}, []string{"metric"})
+
+	DataSourceDegraded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "data_source_degraded",
+			Help: "Whether the metric has not been fetched successfully within the node overdue time.",
+		}, []string{"metric"})
+
+	FilterCacheMiss = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "filter_node_cache_miss_total",
+			Help: "Number of node names in filter requests that were not found in the cache, by metric.",
+		}, []string{"metric"})
+
+	NodeExcluded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "node_excluded",
+			Help: "Whether the node is filtered out by the high/low watermark hysteresis, by metric.",
+		}, []string{"metric", "node"})
+
+	NodeExclusionTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "node_exclusion_transitions_total",
+			Help: "Number of hysteresis state changes, by metric and new state (excluded or recovered).",
+		}, []string{"metric", "state"})
+
+	Reservations = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "in_flight_reservations",
+			Help: "Number of recently scheduled pods whose requests are added to the node load.",
+		})
+
+	DataSourceBackoffState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "data_source_backoff_state",
+			Help: "Query state of each metric, 0 normal, 1 backing off after repeated failures, 2 circuit open.",
+		}, []string{"metric"})
+
+	DataSourceBackoffSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "data_source_backoff_seconds",
+			Help: "Delay before the next query of each metric, without jitter.",
+		}, []string{"metric"})
+
+	ConfigReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_reloads_total",
+			Help: "Number of config file reloads, by the result.",
+		}, []string{"result"})
+
+	ConfigLastReloadSuccess = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful config file reload.",
+		})
+
+	PredicateFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "predicate_failures_total",
+			Help: "Number of nodes filtered out, by predicate.",
+		}, []string{"predicate"})
 )
 
 var registerMetrics sync.Once
@@ -79,7 +166,20 @@ func Register() {
 			SchedulingAlgorithmPriorityEvaluationDuration,
 			FromPrometheusGetDataEvaluationDuration,
 			FromPrometheusGetDataError,
-			CacheSize)
+			PrometheusEndpointUp,
+			PrometheusEndpointError,
+			PrometheusEndpointDuration,
+			CacheSize,
+			PredicateFailures,
+			FilterCacheMiss,
+			DataSourceDegraded,
+			NodeExcluded,
+			NodeExclusionTransitions,
+			Reservations,
+			ConfigReloads,
+			ConfigLastReloadSuccess,
+			DataSourceBackoffState,
+			DataSourceBackoffSeconds)
 		PrometheusHandler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
 
 	})
@@ -92,6 +192,14 @@ func Register() {
 	PodSchedulePrioritySuccesses = scheduleAttempts.With(prometheus.Labels{"result": "success", "algorithm": "priority"})
 	// PodScheduleErrors counts how many pods could not be scheduled due to a scheduler error.
 	PodSchedulePriority = scheduleAttempts.With(prometheus.Labels{"result": "count", "algorithm": "priority"})
+	// PodBindSuccesses counts how many pods were bound by the extender.
+	PodBindSuccesses = scheduleAttempts.With(prometheus.Labels{"result": "success", "algorithm": "bind"})
+	// PodBind counts how many bind requests were received.
+	PodBind = scheduleAttempts.With(prometheus.Labels{"result": "count", "algorithm": "bind"})
+	// PodPreemptSuccesses counts how many preemption requests were processed.
+	PodPreemptSuccesses = scheduleAttempts.With(prometheus.Labels{"result": "success", "algorithm": "preempt"})
+	// PodPreempt counts how many preemption requests were received.
+	PodPreempt = scheduleAttempts.With(prometheus.Labels{"result": "count", "algorithm": "preempt"})
 
 }
 
@@ -0,0 +1,163 @@
+package routers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/common/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"kube-scheduler-extender/algorithm"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"kube-scheduler-extender/util"
+	"net/http"
+	"strings"
+)
+
+type adminError struct {
+	Error string `json:"error"`
+}
+
+// ExplainArgs explain 请求, pod 和 namespace/name 二选一, nodes 为空时使用所有 node
+type ExplainArgs struct {
+	Pod       *v1.Pod  `json:"pod"`
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Nodes     []string `json:"nodes"`
+}
+
+// EnableAdmin 注册 /admin/ 下的只读接口,请求需要携带 Authorization: Bearer <token>
+func EnableAdmin(token util.TokenSource) {
+	Router.GET("/admin/cache", adminAuth(token, AdminCache))
+	Router.GET("/admin/config", adminAuth(token, AdminConfig))
+	Router.GET("/admin/health", adminAuth(token, AdminHealth))
+	Router.POST("/admin/explain", adminAuth(token, AdminExplain))
+}
+
+func adminAuth(token util.TokenSource, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		expected, err := token()
+		if err != nil {
+			log.Errorln("读取 admin token 出错: ", err)
+		}
+		header := r.Header.Get("Authorization")
+		got := strings.TrimPrefix(header, "Bearer ")
+		// token 为空时拒绝所有请求
+		if err != nil || expected == "" || got == header || subtle.ConstantTimeCompare([]byte(got), []byte(expected)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, adminError{Error: "unauthorized"})
+			return
+		}
+		h(w, r, ps)
+	}
+}
+
+// AdminCache 返回节点缓存, 支持 ?node=a,b 按 node 名、?selector=k=v 按 node label、?metric=memory 按指标过滤
+func AdminCache(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	q := r.URL.Query()
+	filter := controller.NodeFilter{Metric: q.Get("metric")}
+	for _, v := range q["node"] {
+		for _, name := range strings.Split(v, ",") {
+			if name != "" {
+				filter.Names = append(filter.Names, name)
+			}
+		}
+	}
+	if v := q.Get("selector"); v != "" {
+		selector, err := labels.Parse(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+			return
+		}
+		filter.Selector = selector
+	}
+
+	nodes, err := controller.NodeInfo.Snapshot(filter)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Nodes []controller.CachedNode `json:"nodes"`
+	}{nodes})
+}
+
+// AdminConfig 返回当前生效的配置和算法, prometheus url 中的密码、bearer token、basic auth 密码和 header 值被隐藏
+func AdminConfig(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	conf.Lock.RLock()
+	c := *conf.Get()
+	plugins := algorithm.ActivePlugins()
+	conf.Lock.RUnlock()
+
+	urls := make([]string, 0, len(c.PrometheusUrls))
+	for _, u := range c.PrometheusUrls {
+		urls = append(urls, util.RedactURL(u))
+	}
+	c.PrometheusUrls = urls
+	if c.DataSource.Prometheus.BearerToken != "" {
+		c.DataSource.Prometheus.BearerToken = util.Redacted
+	}
+	if c.DataSource.Prometheus.BasicAuthPassword != "" {
+		c.DataSource.Prometheus.BasicAuthPassword = util.Redacted
+	}
+	c.DataSource.Prometheus.Headers = util.RedactHeaders(c.DataSource.Prometheus.Headers)
+
+	writeJSON(w, http.StatusOK, struct {
+		Config  conf.Config        `json:"config"`
+		Plugins conf.PluginsConfig `json:"plugins"`
+	}{c, plugins})
+}
+
+// AdminHealth 返回数据源每个指标的查询状态
+func AdminHealth(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	writeJSON(w, http.StatusOK, controller.NodeInfo.Health())
+}
+
+// AdminExplain 对 pod 执行所有预选和优选算法, 返回每个 node 每个算法的结果, 不影响调度
+func AdminExplain(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var args ExplainArgs
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		writeJSON(w, http.StatusBadRequest, adminError{Error: "解析参数错误: " + err.Error()})
+		return
+	}
+
+	pod := args.Pod
+	if pod == nil {
+		if args.Name == "" {
+			writeJSON(w, http.StatusBadRequest, adminError{Error: "需要 pod 或 namespace/name"})
+			return
+		}
+		if args.Namespace == "" {
+			args.Namespace = "default"
+		}
+		var err error
+		if pod, err = controller.GetPod(args.Namespace, args.Name); err != nil {
+			writeJSON(w, http.StatusBadRequest, adminError{Error: err.Error()})
+			return
+		}
+	}
+
+	nodes := args.Nodes
+	if len(nodes) == 0 {
+		var err error
+		if nodes, err = controller.NodeInfo.NodeNames(); err != nil {
+			writeJSON(w, http.StatusInternalServerError, adminError{Error: err.Error()})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, algorithm.Explain(pod, nodes))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	response, err := json.Marshal(v)
+	if err != nil {
+		log.Errorln("json 格式化 admin 响应出错:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
@@ -0,0 +1,19 @@
+package routers
+
+import (
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"testing"
+)
+
+// setTestConfig 使用 c 作为当前配置,测试结束后恢复配置和 controller.NodeInfo
+func setTestConfig(t *testing.T, c *conf.Config) {
+	oldConf, oldNodeInfo := conf.Get(), controller.NodeInfo
+	conf.Store(c)
+	t.Cleanup(func() {
+		if oldConf != nil {
+			conf.Store(oldConf)
+		}
+		controller.NodeInfo = oldNodeInfo
+	})
+}
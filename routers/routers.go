@@ -8,8 +8,10 @@ import (
 	"io"
 	"kube-scheduler-extender/algorithm"
 	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
 	"kube-scheduler-extender/metrics"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
@@ -34,6 +36,11 @@ func Index(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 }
 
 func HealthCheck(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	// 数据源降级不影响 extender 本身提供服务,仍然返回 200,避免被 liveness probe 重启
+	if degraded := controller.NodeInfo.Degraded(); len(degraded) != 0 {
+		fmt.Fprintf(w, "DEGRADED: %v, stale policy: %v\n", strings.Join(degraded, ","), conf.Get().Staleness.Policy)
+		return
+	}
 	fmt.Fprint(w, "OK\n")
 
 }
@@ -54,7 +61,7 @@ func Filter(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 			Error: err.Error(),
 		}
 	} else {
-		if conf.Conf.LogRequestBody {
+		if conf.Get().LogRequestBody {
 			b, _ := json.Marshal(extenderArgs)
 			log.Infoln(string(b))
 		}
@@ -88,7 +95,7 @@ func Prioritize(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		log.Errorln("解析参数错误:", err)
 		hostPriorityList = &schedulerapi.HostPriorityList{}
 	} else {
-		if conf.Conf.LogRequestBody {
+		if conf.Get().LogRequestBody {
 			b, _ := json.Marshal(extenderArgs)
 			log.Infoln(string(b))
 		}
@@ -108,4 +115,72 @@ func Prioritize(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	}
 }
 
-// func Bind(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {}
+// EnablePreempt 注册 /preempt,需要先启动 node 和 pod informer
+func EnablePreempt() {
+	Router.POST("/preempt", Preempt)
+}
+
+func Preempt(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	defer metrics.PodPreempt.Inc()
+
+	var extenderPreemptionArgs schedulerapi.ExtenderPreemptionArgs
+	var extenderPreemptionResult *schedulerapi.ExtenderPreemptionResult
+	if err := json.NewDecoder(r.Body).Decode(&extenderPreemptionArgs); err != nil || extenderPreemptionArgs.Pod == nil {
+		log.Errorln("解析参数错误:", err)
+		// 返回空结果,调度器不会在任何 node 上抢占
+		extenderPreemptionResult = &schedulerapi.ExtenderPreemptionResult{}
+	} else {
+		if conf.Get().LogRequestBody {
+			b, _ := json.Marshal(extenderPreemptionArgs)
+			log.Infoln(string(b))
+		}
+
+		extenderPreemptionResult = algorithm.ProcessPreemption(extenderPreemptionArgs)
+	}
+
+	if response, err := json.Marshal(extenderPreemptionResult); err != nil {
+		log.Errorln("json 格式化 extenderPreemptionResult:", err)
+		panic(err)
+	} else {
+		metrics.PodPreemptSuccesses.Inc()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// EnableBind 注册 /bind,由 extender 代替 kube-scheduler 创建 Binding
+func EnableBind() {
+	Router.POST("/bind", Bind)
+}
+
+func Bind(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	defer metrics.PodBind.Inc()
+
+	var extenderBindingArgs schedulerapi.ExtenderBindingArgs
+	var extenderBindingResult schedulerapi.ExtenderBindingResult
+	if err := json.NewDecoder(r.Body).Decode(&extenderBindingArgs); err != nil {
+		log.Errorln("解析参数错误:", err)
+		extenderBindingResult.Error = err.Error()
+	} else {
+		if conf.Get().LogRequestBody {
+			b, _ := json.Marshal(extenderBindingArgs)
+			log.Infoln(string(b))
+		}
+
+		if err := controller.Bind(controller.KubeClient, extenderBindingArgs); err != nil {
+			extenderBindingResult.Error = err.Error()
+		} else {
+			metrics.PodBindSuccesses.Inc()
+		}
+	}
+
+	if response, err := json.Marshal(extenderBindingResult); err != nil {
+		log.Errorln("json 格式化 extenderBindingResult:", err)
+		panic(err)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
@@ -0,0 +1,176 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+	schedulerapi "k8s.io/kube-scheduler/extender/v1"
+	"kube-scheduler-extender/algorithm"
+	"kube-scheduler-extender/conf"
+	"kube-scheduler-extender/controller"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterNodes(t *testing.T) {
+	c := &conf.Config{
+		Metrics: []conf.MetricConfig{
+			{Name: conf.MemoryMetricName, Plugin: "CheckMemoryLoad", Comparison: conf.ComparisonAbove, Threshold: 80, ScoreMax: 100, Weight: 1},
+			{Name: conf.CPUMetricName, Plugin: "CheckCpuLoad", Comparison: conf.ComparisonAbove, Threshold: 80, ScoreMax: 100, Weight: 1},
+		},
+		Staleness: conf.StalenessConfig{Policy: conf.StalePolicyAllow},
+		Intervals: conf.IntervalsConfig{Overdue: time.Minute},
+	}
+	setTestConfig(t, c)
+	plugins, err := algorithm.NewPlugins(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	algorithm.SetPlugins(plugins)
+	now := time.Now()
+	controller.NodeInfo = &controller.Nodes{NodeMetrics: map[string]map[string]*controller.NodeMetric{
+		conf.MemoryMetricName: {
+			"node-1": {NodeName: "node-1", Value: 50, CheckTime: now},
+			"node-2": {NodeName: "node-2", Value: 90, CheckTime: now},
+		},
+		conf.CPUMetricName: {},
+	}}
+
+	node := func(name string) v1.Node {
+		return v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"zone": "a"}}}
+	}
+	args := schedulerapi.ExtenderArgs{
+		Pod:   &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}},
+		Nodes: &v1.NodeList{Items: []v1.Node{node("node-1"), node("node-2"), node("node-3")}},
+	}
+
+	tests := []struct {
+		name            string
+		args            schedulerapi.ExtenderArgs
+		wantNodes       []v1.Node
+		wantFailedNodes schedulerapi.FailedNodesMap
+		wantError       string
+	}{
+		{
+			name:            "nodeCacheCapable 为 false 时返回 node 详情",
+			args:            args,
+			wantNodes:       []v1.Node{node("node-1"), node("node-3")},
+			wantFailedNodes: schedulerapi.FailedNodesMap{"node-2": "node memory load high"},
+		},
+		{
+			name:      "NodeNames 和 Nodes 都为空",
+			args:      schedulerapi.ExtenderArgs{Pod: args.Pod},
+			wantError: "请求中 NodeNames 和 Nodes 都为空",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			Filter(w, httptest.NewRequest(http.MethodPost, "/filter", strings.NewReader(string(body))), nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("状态码 %v, 期望 %v", w.Code, http.StatusOK)
+			}
+
+			var result schedulerapi.ExtenderFilterResult
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Error != tt.wantError {
+				t.Fatalf("Error = %q, 期望 %q", result.Error, tt.wantError)
+			}
+			if result.NodeNames != nil {
+				t.Errorf("NodeNames = %v, 期望为空", *result.NodeNames)
+			}
+			if tt.wantError != "" {
+				return
+			}
+			if result.Nodes == nil {
+				t.Fatal("Nodes 为空")
+			}
+			// 并发预选,结果顺序不固定
+			got := map[string]v1.Node{}
+			for _, n := range result.Nodes.Items {
+				got[n.Name] = n
+			}
+			want := map[string]v1.Node{}
+			for _, n := range tt.wantNodes {
+				want[n.Name] = n
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Nodes = %v, 期望 %v", result.Nodes.Items, tt.wantNodes)
+			}
+			if !reflect.DeepEqual(result.FailedNodes, tt.wantFailedNodes) {
+				t.Errorf("FailedNodes = %v, 期望 %v", result.FailedNodes, tt.wantFailedNodes)
+			}
+		})
+	}
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		bindErr   error
+		wantError string
+	}{
+		{
+			name: "绑定成功",
+			body: `{"podName":"web","podNamespace":"default","podUID":"uid-web","node":"node-1"}`,
+		},
+		{
+			name:      "绑定失败返回错误",
+			body:      `{"podName":"web","podNamespace":"default","podUID":"uid-web","node":"node-1"}`,
+			bindErr:   errors.New("binding forbidden"),
+			wantError: "binding forbidden",
+		},
+		{
+			name:      "参数不合法",
+			body:      `{`,
+			wantError: "unexpected EOF",
+		},
+	}
+
+	setTestConfig(t, &conf.Config{})
+	oldClient := controller.KubeClient
+	defer func() { controller.KubeClient = oldClient }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			client.PrependReactor("create", "pods", func(action core.Action) (bool, runtime.Object, error) {
+				if _, ok := action.(core.CreateAction).GetObject().(*v1.Binding); !ok {
+					return false, nil, nil
+				}
+				return true, nil, tt.bindErr
+			})
+			controller.KubeClient = client
+
+			w := httptest.NewRecorder()
+			Bind(w, httptest.NewRequest(http.MethodPost, "/bind", strings.NewReader(tt.body)), nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("状态码 %v, 期望 %v", w.Code, http.StatusOK)
+			}
+
+			var result schedulerapi.ExtenderBindingResult
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Error != tt.wantError {
+				t.Errorf("ExtenderBindingResult.Error = %q, 期望 %q", result.Error, tt.wantError)
+			}
+		})
+	}
+}
@@ -2,8 +2,11 @@
 
 package debugger
 
-import "syscall"
+import (
+	"os"
+	"syscall"
+)
 
 // compareSignal is the signal to trigger node info. For non-windows
 // environment it's SIGUSR2.
-var CompareSignal = syscall.SIGUSR2
+var CompareSignal os.Signal = syscall.SIGUSR2
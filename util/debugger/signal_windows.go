@@ -2,6 +2,6 @@ package debugger
 
 import "os"
 
-// compareSignal is the signal to trigger node info. For windows,
-// it's SIGINT.
-var CompareSignal = os.Interrupt
+// compareSignal is the signal to trigger node info. Windows has no spare
+// signal, SIGINT is used for shutdown, use the admin API instead.
+var CompareSignal os.Signal
@@ -0,0 +1,266 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPClientOptions 访问需要认证的 http 服务(例如 prometheus、thanos、cortex)的配置
+type HTTPClientOptions struct {
+	// BearerToken 和 BearerTokenFile 二选一,文件内容变化后自动重新读取
+	BearerToken     string
+	BearerTokenFile string
+
+	BasicAuthUsername string
+	BasicAuthPassword string
+
+	// CAFile 校验服务端证书的 CA
+	CAFile string
+	// CertFile, KeyFile 客户端证书,文件变化后自动重新加载
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+
+	// Headers 每个请求附加的 header, 例如 X-Scope-OrgID
+	Headers map[string]string
+
+	Timeout time.Duration
+}
+
+// ParseHeaders 解析 "k1=v1&k2=v2" 格式的 header 配置
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, v := range strings.Split(s, "&") {
+		if v == "" {
+			continue
+		}
+		item := strings.SplitN(v, "=", 2)
+		if len(item) != 2 || item[0] == "" {
+			return nil, fmt.Errorf("header %v 格式错误, 应为 key=value", v)
+		}
+		headers[item[0]] = item[1]
+	}
+	return headers, nil
+}
+
+// Redacted 替换日志和 admin 接口中的密码、token 和 header 值
+const Redacted = "xxxxx"
+
+// RedactURL 隐藏 url 中的密码
+func RedactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, exist := u.User.Password(); exist {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	return u.String()
+}
+
+// RedactHeaders 返回隐藏了值的 header 副本, header 中可能带有租户 token
+func RedactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(headers))
+	for k := range headers {
+		redacted[k] = Redacted
+	}
+	return redacted
+}
+
+// NewHTTPClient 根据配置创建 http client
+func NewHTTPClient(o HTTPClientOptions) (*http.Client, error) {
+	if o.BearerToken != "" && o.BearerTokenFile != "" {
+		return nil, errors.New("bearer token 和 bearer token file 只能配置一个")
+	}
+	if (o.BearerToken != "" || o.BearerTokenFile != "") && o.BasicAuthUsername != "" {
+		return nil, errors.New("bearer token 和 basic auth 只能配置一个")
+	}
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, errors.New("客户端证书需要同时配置 cert file 和 key file")
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+	if o.CAFile != "" {
+		ca, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("ca file %v 中没有合法的证书", o.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if o.CertFile != "" {
+		cert := &certReloader{certFile: o.CertFile, keyFile: o.KeyFile}
+		if _, err := cert.get(); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.get()
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+
+	rt := &authRoundTripper{
+		next:     transport,
+		token:    o.BearerToken,
+		username: o.BasicAuthUsername,
+		password: o.BasicAuthPassword,
+		headers:  o.Headers,
+	}
+	if o.BearerTokenFile != "" {
+		rt.tokenFile = &fileReloader{path: o.BearerTokenFile}
+		if _, err := rt.tokenFile.get(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &http.Client{
+		Transport: rt,
+		Timeout:   o.Timeout,
+	}, nil
+}
+
+// authRoundTripper 为每个请求添加认证信息和自定义 header
+type authRoundTripper struct {
+	next http.RoundTripper
+
+	token     string
+	tokenFile *fileReloader
+	username  string
+	password  string
+	headers   map[string]string
+}
+
+func (rt *authRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper 不能修改传入的 request
+	r := req.Clone(req.Context())
+	for k, v := range rt.headers {
+		r.Header.Set(k, v)
+	}
+
+	token := rt.token
+	if rt.tokenFile != nil {
+		b, err := rt.tokenFile.get()
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	switch {
+	case token != "":
+		r.Header.Set("Authorization", "Bearer "+token)
+	case rt.username != "":
+		r.SetBasicAuth(rt.username, rt.password)
+	}
+
+	return rt.next.RoundTrip(r)
+}
+
+// fileReloader 缓存文件内容,文件修改时间或大小变化后重新读取,用于轮转的 token
+type fileReloader struct {
+	path string
+
+	lock    sync.Mutex
+	modTime time.Time
+	size    int64
+	content []byte
+}
+
+func (f *fileReloader) get() ([]byte, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		// 文件轮转过程中可能短暂不存在,使用上一次读取的内容
+		if f.content != nil {
+			return f.content, nil
+		}
+		return nil, err
+	}
+	if f.content != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.content, nil
+	}
+
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		if f.content != nil {
+			return f.content, nil
+		}
+		return nil, err
+	}
+	f.modTime, f.size, f.content = info.ModTime(), info.Size(), content
+	return content, nil
+}
+
+// certReloader 缓存客户端证书,证书或私钥文件变化后重新加载
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	lock    sync.Mutex
+	modTime time.Time
+	cert    *tls.Certificate
+}
+
+func (c *certReloader) get() (*tls.Certificate, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	modTime, err := latestModTime(c.certFile, c.keyFile)
+	if err != nil {
+		if c.cert != nil {
+			return c.cert, nil
+		}
+		return nil, err
+	}
+	if c.cert != nil && modTime.Equal(c.modTime) {
+		return c.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		// 证书和私钥可能没有同时更新完成,继续使用旧证书
+		if c.cert != nil {
+			return c.cert, nil
+		}
+		return nil, err
+	}
+	c.modTime, c.cert = modTime, &cert
+	return c.cert, nil
+}
+
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,28 @@
+package util
+
+import (
+	"errors"
+	"strings"
+)
+
+// TokenSource 返回当前的 token
+type TokenSource func() (string, error)
+
+// NewTokenSource token 和 tokenFile 二选一,文件内容变化后自动重新读取
+func NewTokenSource(token, tokenFile string) (TokenSource, error) {
+	if token != "" && tokenFile != "" {
+		return nil, errors.New("token 和 token file 只能配置一个")
+	}
+	if tokenFile == "" {
+		return func() (string, error) { return token, nil }, nil
+	}
+
+	f := &fileReloader{path: tokenFile}
+	if _, err := f.get(); err != nil {
+		return nil, err
+	}
+	return func() (string, error) {
+		b, err := f.get()
+		return strings.TrimSpace(string(b)), err
+	}, nil
+}
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewTokenSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(path, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		tokenFile string
+		want      string
+		wantErr   bool
+	}{
+		{name: "token", token: "secret", want: "secret"},
+		{name: "没有配置", want: ""},
+		{name: "token file 去掉空白", tokenFile: path, want: "secret"},
+		{name: "同时配置", token: "secret", tokenFile: path, wantErr: true},
+		{name: "token file 不存在", tokenFile: filepath.Join(dir, "missing"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewTokenSource(tt.token, tt.tokenFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTokenSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got, err := source(); err != nil || got != tt.want {
+				t.Errorf("token = %q, %v, 期望 %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenSourceReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewTokenSource("", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 文件轮转后读取新的 token
+	if err := ioutil.WriteFile(path, []byte("rotated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := source(); err != nil || got != "rotated" {
+		t.Errorf("轮转后 token = %q, %v, 期望 rotated", got, err)
+	}
+
+	// 文件短暂不存在时使用上一次读取的 token
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := source(); err != nil || got != "rotated" {
+		t.Errorf("文件不存在时 token = %q, %v, 期望 rotated", got, err)
+	}
+}